@@ -0,0 +1,285 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SalesPeriod is the length of the periods SalesSummary groups Payments by.
+type SalesPeriod string
+
+// Periods of SalesSummary.
+const (
+	PeriodDay   SalesPeriod = "day"
+	PeriodWeek  SalesPeriod = "week" // Weeks start on Monday.
+	PeriodMonth SalesPeriod = "month"
+)
+
+// start returns the start of the period containing t, in loc.
+func (p SalesPeriod) start(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+
+	switch p {
+	case PeriodWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // Days since Monday
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, loc)
+	case PeriodMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// AnalyticsOptions is a struct that holds the options of AnalyzePayments and SalesSummary.
+// Only Payments created between Since (inclusive) and Until (exclusive) are analyzed. Zero values mean no limit.
+// Period is the length of the periods of SalesSummary.ByPeriod. If not provided, it defaults to PeriodDay.
+// Periods start at midnight in Location. If not provided, it defaults to UTC.
+//
+// The DERO Merchant API does not return when a Payment was paid. PaidAt, if provided, returns it
+// (e.g. from the time the paid webhook was received) and is used to compute the average time-to-pay.
+type AnalyticsOptions struct {
+	Since          time.Time
+	Until          time.Time
+	Period         SalesPeriod
+	Location       *time.Location
+	CurrencyFilter string
+	PaidAt         func(p *Payment) (time.Time, bool)
+
+	PageSize int
+}
+
+// SalesStats holds the statistics of a group of Payments.
+// PaidAmount and PaidAtomicDeroAmount only count paid Payments. PaidAmount is by currency.
+// ConversionRate is the ratio of paid Payments to paid and expired Payments.
+// AverageTimeToPay is only computed if AnalyticsOptions.PaidAt is provided. It is marshalled to JSON in nanoseconds.
+// AverageExchangeRate is by currency.
+type SalesStats struct {
+	Payments             int                `json:"payments"`
+	ByStatus             map[string]int     `json:"byStatus"`
+	PaidAmount           map[string]float64 `json:"paidAmount"`
+	PaidAtomicDeroAmount uint64             `json:"paidAtomicDeroAmount"`
+	ConversionRate       float64            `json:"conversionRate"`
+	AverageTimeToPay     time.Duration      `json:"averageTimeToPay"`
+	AverageExchangeRate  map[string]float64 `json:"averageExchangeRate"`
+
+	timeToPaySum    time.Duration
+	timeToPayCount  int
+	exchangeRateSum map[string]float64
+	exchangeRateN   map[string]int
+}
+
+func newSalesStats() *SalesStats {
+	return &SalesStats{
+		ByStatus:            make(map[string]int),
+		PaidAmount:          make(map[string]float64),
+		AverageExchangeRate: make(map[string]float64),
+		exchangeRateSum:     make(map[string]float64),
+		exchangeRateN:       make(map[string]int),
+	}
+}
+
+func (s *SalesStats) add(p *Payment, paidAt func(p *Payment) (time.Time, bool)) {
+	s.Payments++
+	s.ByStatus[p.Status]++
+
+	if p.ExchangeRate > 0 {
+		s.exchangeRateSum[p.Currency] += p.ExchangeRate
+		s.exchangeRateN[p.Currency]++
+	}
+
+	if p.Status != PaymentStatusPaid {
+		return
+	}
+
+	s.PaidAmount[p.Currency] += p.CurrencyAmount
+	s.PaidAtomicDeroAmount += p.AtomicDeroAmount
+
+	if paidAt != nil {
+		t, ok := paidAt(p)
+		if ok && !t.Before(p.CreationTime) {
+			s.timeToPaySum += t.Sub(p.CreationTime)
+			s.timeToPayCount++
+		}
+	}
+}
+
+// finish computes the ratios and averages of s.
+func (s *SalesStats) finish() {
+	paid, expired := s.ByStatus[PaymentStatusPaid], s.ByStatus[PaymentStatusExpired]
+	if paid+expired > 0 {
+		s.ConversionRate = float64(paid) / float64(paid+expired)
+	}
+
+	if s.timeToPayCount > 0 {
+		s.AverageTimeToPay = s.timeToPaySum / time.Duration(s.timeToPayCount)
+	}
+
+	for currency, sum := range s.exchangeRateSum {
+		s.AverageExchangeRate[currency] = sum / float64(s.exchangeRateN[currency])
+	}
+}
+
+// PeriodSales holds the statistics of the Payments created in the period starting at Start.
+type PeriodSales struct {
+	Start time.Time `json:"start"`
+	*SalesStats
+}
+
+// SalesSummary holds the statistics of the Payments created in a date range: in total, by currency and by period (oldest first).
+// Since and Until are omitted from its JSON if zero.
+type SalesSummary struct {
+	Since      time.Time              `json:"since"`
+	Until      time.Time              `json:"until"`
+	Period     SalesPeriod            `json:"period"`
+	Total      *SalesStats            `json:"total"`
+	ByCurrency map[string]*SalesStats `json:"byCurrency"`
+	ByPeriod   []*PeriodSales         `json:"byPeriod"`
+}
+
+// MarshalJSON marshals s, omitting Since and Until if zero (omitempty has no effect on time.Time).
+func (s SalesSummary) MarshalJSON() ([]byte, error) {
+	type summary SalesSummary // Without the MarshalJSON method
+	v := struct {
+		Since *time.Time `json:"since,omitempty"`
+		Until *time.Time `json:"until,omitempty"`
+		summary
+	}{summary: summary(s)}
+
+	if !s.Since.IsZero() {
+		v.Since = &s.Since
+	}
+	if !s.Until.IsZero() {
+		v.Until = &s.Until
+	}
+
+	return json.Marshal(v)
+}
+
+// salesAnalyzer accumulates the statistics of a SalesSummary.
+type salesAnalyzer struct {
+	o        AnalyticsOptions
+	summary  *SalesSummary
+	byPeriod map[int64]*PeriodSales
+}
+
+func newSalesAnalyzer(o *AnalyticsOptions) (*salesAnalyzer, error) {
+	a := &salesAnalyzer{
+		byPeriod: make(map[int64]*PeriodSales),
+	}
+	if o != nil {
+		a.o = *o
+	}
+
+	if a.o.Period == "" {
+		a.o.Period = PeriodDay
+	}
+	if a.o.Location == nil {
+		a.o.Location = time.UTC
+	}
+
+	switch a.o.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+	default:
+		return nil, fmt.Errorf("DeroMerchant: unknown sales period %q", a.o.Period)
+	}
+
+	a.summary = &SalesSummary{
+		Since:      a.o.Since,
+		Until:      a.o.Until,
+		Period:     a.o.Period,
+		Total:      newSalesStats(),
+		ByCurrency: make(map[string]*SalesStats),
+	}
+
+	return a, nil
+}
+
+func (a *salesAnalyzer) add(p *Payment) {
+	if !a.o.Since.IsZero() && p.CreationTime.Before(a.o.Since) {
+		return
+	}
+	if !a.o.Until.IsZero() && !p.CreationTime.Before(a.o.Until) {
+		return
+	}
+	if a.o.CurrencyFilter != "" && p.Currency != a.o.CurrencyFilter {
+		return
+	}
+
+	a.summary.Total.add(p, a.o.PaidAt)
+
+	c, ok := a.summary.ByCurrency[p.Currency]
+	if !ok {
+		c = newSalesStats()
+		a.summary.ByCurrency[p.Currency] = c
+	}
+	c.add(p, a.o.PaidAt)
+
+	start := a.o.Period.start(p.CreationTime, a.o.Location)
+	ps, ok := a.byPeriod[start.Unix()]
+	if !ok {
+		ps = &PeriodSales{Start: start, SalesStats: newSalesStats()}
+		a.byPeriod[start.Unix()] = ps
+	}
+	ps.add(p, a.o.PaidAt)
+}
+
+func (a *salesAnalyzer) finish() *SalesSummary {
+	s := a.summary
+
+	s.Total.finish()
+	for _, c := range s.ByCurrency {
+		c.finish()
+	}
+
+	s.ByPeriod = make([]*PeriodSales, 0, len(a.byPeriod))
+	for _, ps := range a.byPeriod {
+		ps.finish()
+		s.ByPeriod = append(s.ByPeriod, ps)
+	}
+	sort.Slice(s.ByPeriod, func(i, j int) bool {
+		return s.ByPeriod[i].Start.Before(s.ByPeriod[j].Start)
+	})
+
+	return s
+}
+
+// AnalyzePayments returns the SalesSummary of payments. o is optional.
+func AnalyzePayments(payments []*Payment, o *AnalyticsOptions) (*SalesSummary, error) {
+	a, err := newSalesAnalyzer(o)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range payments {
+		a.add(p)
+	}
+
+	return a.finish(), nil
+}
+
+// SalesSummary returns the SalesSummary of the Payments listed by GetFilteredPayments. o is optional.
+// Payments are streamed page by page, so Since should be set to avoid listing the whole payment history.
+func (c *Client) SalesSummary(ctx context.Context, o *AnalyticsOptions) (*SalesSummary, error) {
+	a, err := newSalesAnalyzer(o)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.eachFilteredPayment(ctx, a.o.PageSize, "", a.o.CurrencyFilter, func(p *Payment) (bool, error) {
+		if !a.o.Since.IsZero() && p.CreationTime.Before(a.o.Since) {
+			return false, nil // Payments are listed from newest to oldest
+		}
+
+		a.add(p)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.finish(), nil
+}
@@ -0,0 +1,138 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSalesSummary(t *testing.T) {
+	monday := time.Date(2020, 2, 3, 12, 0, 0, 0, time.UTC)
+	payments := []*Payment{
+		{PaymentID: "a", Status: PaymentStatusPaid, Currency: "EUR", CurrencyAmount: 10, ExchangeRate: 2, AtomicDeroAmount: 5, CreationTime: monday},
+		{PaymentID: "b", Status: PaymentStatusExpired, Currency: "EUR", CurrencyAmount: 20, ExchangeRate: 4, AtomicDeroAmount: 5, CreationTime: monday.Add(time.Hour)},
+		{PaymentID: "c", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 1.5, ExchangeRate: 3, AtomicDeroAmount: 7, CreationTime: monday.AddDate(0, 0, 1)},
+		{PaymentID: "d", Status: PaymentStatusPaid, Currency: "DERO", CurrencyAmount: 1, AtomicDeroAmount: 1000000000000, CreationTime: monday.AddDate(0, 0, 7)},
+		{PaymentID: "e", Status: PaymentStatusPending, Currency: "USD", CurrencyAmount: 1, ExchangeRate: 5, CreationTime: monday.AddDate(0, 0, 8)},
+		{PaymentID: "old", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 1, CreationTime: monday.AddDate(-1, 0, 0)},
+	}
+
+	ts := newTestPaymentsServer(t, payments)
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	paidAfter := map[string]time.Duration{"a": 10 * time.Minute, "c": 20 * time.Minute}
+
+	s, err := c.SalesSummary(context.Background(), &AnalyticsOptions{
+		Since:  monday.AddDate(0, 0, -1),
+		Period: PeriodWeek,
+		PaidAt: func(p *Payment) (time.Time, bool) {
+			d, ok := paidAfter[p.PaymentID]
+			return p.CreationTime.Add(d), ok
+		},
+		PageSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Total.Payments != 5 {
+		t.Errorf("Expected 5 payments. Got: %d\n", s.Total.Payments)
+	}
+	if s.Total.ByStatus[PaymentStatusPaid] != 3 || s.Total.ByStatus[PaymentStatusExpired] != 1 {
+		t.Errorf("Expected 3 paid and 1 expired payments. Got: %v\n", s.Total.ByStatus)
+	}
+	if s.Total.ConversionRate != 0.75 {
+		t.Errorf("Expected conversion rate: 0.75. Got: %f\n", s.Total.ConversionRate)
+	}
+	if s.Total.AverageTimeToPay != 15*time.Minute {
+		t.Errorf("Expected average time to pay: 15m. Got: %v\n", s.Total.AverageTimeToPay)
+	}
+	if s.Total.PaidAtomicDeroAmount != 1000000000012 {
+		t.Errorf("Expected paid atomic DERO amount: 1000000000012. Got: %d\n", s.Total.PaidAtomicDeroAmount)
+	}
+
+	eur := s.ByCurrency["EUR"]
+	if eur == nil || eur.PaidAmount["EUR"] != 10 || eur.AverageExchangeRate["EUR"] != 3 || eur.ConversionRate != 0.5 {
+		t.Errorf("Unexpected EUR stats: %+v\n", eur)
+	}
+
+	if len(s.ByPeriod) != 2 {
+		t.Fatalf("Expected 2 weeks. Got: %d\n", len(s.ByPeriod))
+	}
+	if !s.ByPeriod[0].Start.Equal(time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC)) || s.ByPeriod[0].Payments != 3 {
+		t.Errorf("Unexpected first week: %v with %d payments\n", s.ByPeriod[0].Start, s.ByPeriod[0].Payments)
+	}
+	if s.ByPeriod[1].Payments != 2 || s.ByPeriod[1].AverageExchangeRate["USD"] != 5 {
+		t.Errorf("Unexpected second week: %+v\n", s.ByPeriod[1].SalesStats)
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded["since"] != s.Since.Format(time.RFC3339Nano) {
+		t.Errorf("Expected since in JSON. Got: %s\n", b)
+	}
+	periods := decoded["byPeriod"].([]interface{})
+	if _, ok := periods[0].(map[string]interface{})["conversionRate"]; !ok {
+		t.Errorf("Expected period stats to be inlined in JSON. Got: %s\n", b)
+	}
+}
+
+func TestAnalyzePayments(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip("Time zone database not available")
+	}
+
+	payments := []*Payment{
+		{Status: PaymentStatusPaid, Currency: "EUR", CreationTime: time.Date(2020, 1, 31, 23, 30, 0, 0, time.UTC)}, // February 1st in Rome
+		{Status: PaymentStatusPaid, Currency: "EUR", CreationTime: time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC)},
+	}
+
+	s, err := AnalyzePayments(payments, &AnalyticsOptions{Period: PeriodMonth, Location: rome})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ByPeriod) != 2 || s.ByPeriod[1].Start.Month() != time.February {
+		t.Errorf("Expected January and February. Got: %d periods\n", len(s.ByPeriod))
+	}
+
+	// No range is marshalled without since and until
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	err = json.Unmarshal(b, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded["since"]; ok {
+		t.Errorf("Expected zero since to be omitted. Got: %s\n", b)
+	}
+	if _, ok := decoded["until"]; ok {
+		t.Errorf("Expected zero until to be omitted. Got: %s\n", b)
+	}
+	if _, ok := decoded["total"]; !ok {
+		t.Errorf("Expected total in JSON. Got: %s\n", b)
+	}
+
+	_, err = AnalyzePayments(payments, &AnalyticsOptions{Period: "year"})
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
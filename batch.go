@@ -0,0 +1,204 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Defaults of BatchOptions.
+// The API does not document a maximum number of Payment IDs per request: DefaultBatchSize is a conservative choice of the SDK, not a server limit.
+const (
+	DefaultBatchSize        = 100
+	DefaultBatchConcurrency = 4
+)
+
+// BatchOptions is a struct that holds the options of GetPaymentsBatch.
+// BatchSize is the maximum number of Payment IDs sent in a single request, Concurrency the maximum number of requests sent at the same time.
+// If not provided, they default to DefaultBatchSize and DefaultBatchConcurrency.
+type BatchOptions struct {
+	BatchSize   int
+	Concurrency int
+}
+
+// GetPaymentsResult is a struct that holds the result of GetPaymentsBatch.
+// Payments holds the Payments found, in the order of the requested Payment IDs and without duplicates.
+// NotFound holds the requested Payment IDs the server returned no Payment for.
+// Payment IDs of batches that failed are in neither.
+type GetPaymentsResult struct {
+	Payments []*Payment
+	NotFound []string
+}
+
+// BatchChunkError is the error of a single batch of GetPaymentsBatch.
+type BatchChunkError struct {
+	PaymentIDs []string
+	Err        error
+}
+
+func (e *BatchChunkError) Error() string {
+	return fmt.Sprintf("batch of %d payment IDs: %v", len(e.PaymentIDs), e.Err)
+}
+
+func (e *BatchChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is returned by GetPaymentsBatch when some of its batches failed.
+// Errors are in the order of the batches, which follows the order of the requested Payment IDs.
+// errors.Is and errors.As match any of the errors of the failed batches.
+type BatchError struct {
+	Errors []*BatchChunkError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("DeroMerchant Client: %d batches failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the errors of the failed batches matches target.
+func (e *BatchError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error of the failed batches that matches target.
+func (e *BatchError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FailedPaymentIDs returns the Payment IDs of the failed batches.
+func (e *BatchError) FailedPaymentIDs() []string {
+	var ids []string
+	for _, err := range e.Errors {
+		ids = append(ids, err.PaymentIDs...)
+	}
+
+	return ids
+}
+
+// dedupe returns ids without duplicates, in order of first occurrence.
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+
+	return unique
+}
+
+// GetPaymentsBatch gets the Payments of paymentIDs, split in batches sent with bounded concurrency.
+// Duplicate Payment IDs are removed. o is optional.
+// If some batches fail, the result of the others is returned along with a BatchError.
+func (c *Client) GetPaymentsBatch(ctx context.Context, paymentIDs []string, o *BatchOptions) (*GetPaymentsResult, error) {
+	size, concurrency := DefaultBatchSize, DefaultBatchConcurrency
+	if o != nil && o.BatchSize > 0 {
+		size = o.BatchSize
+	}
+	if o != nil && o.Concurrency > 0 {
+		concurrency = o.Concurrency
+	}
+
+	ids := dedupe(paymentIDs)
+
+	var chunks [][]string
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunks = append(chunks, ids[start:end])
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		found  = make(map[string]*Payment, len(ids))
+		failed = make(map[string]bool)
+		errs   = make([]*BatchChunkError, len(chunks)) // By chunk index, so errors are in the order of paymentIDs
+		sem    = make(chan struct{}, concurrency)
+	)
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk []string) {
+			defer wg.Done()
+
+			var (
+				payments []*Payment
+				err      error
+			)
+			select {
+			case sem <- struct{}{}:
+				payments, err = c.getPaymentsCoalesced(ctx, chunk)
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs[i] = &BatchChunkError{PaymentIDs: chunk, Err: err}
+				for _, id := range chunk {
+					failed[id] = true
+				}
+				return
+			}
+
+			for _, p := range payments {
+				if p != nil {
+					found[p.PaymentID] = p
+				}
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var failedChunks []*BatchChunkError
+	for _, err := range errs {
+		if err != nil {
+			failedChunks = append(failedChunks, err)
+		}
+	}
+
+	res := &GetPaymentsResult{
+		Payments: make([]*Payment, 0, len(found)),
+	}
+	for _, id := range ids {
+		if p, ok := found[id]; ok {
+			res.Payments = append(res.Payments, p)
+		} else if !failed[id] {
+			res.NotFound = append(res.NotFound, id)
+		}
+	}
+
+	if len(failedChunks) > 0 {
+		return res, &BatchError{Errors: failedChunks}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,138 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetPaymentsBatch(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		inFlight    int
+		maxInFlight int
+		maxBatch    int
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		err = json.Unmarshal(body, &ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		if len(ids) > maxBatch {
+			maxBatch = len(ids)
+		}
+		mu.Unlock()
+
+		// Server returns payments in reverse order and omits missing ones
+		var payments []*Payment
+		for i := len(ids) - 1; i >= 0; i-- {
+			if strings.HasPrefix(ids[i], "fail") {
+				err := sendErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !strings.HasPrefix(ids[i], "missing") {
+				payments = append(payments, &Payment{PaymentID: ids[i]})
+			}
+		}
+
+		resp, err := json.Marshal(payments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	var ids []string
+	for i := 0; i < 25; i++ {
+		ids = append(ids, fmt.Sprintf("p%02d", i))
+	}
+	ids = append(ids, "p03", "missing1", "p00", "missing2")
+
+	res, err := c.GetPaymentsBatch(context.Background(), ids, &BatchOptions{BatchSize: 4, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Payments) != 25 {
+		t.Fatalf("Expected 25 payments. Got: %d\n", len(res.Payments))
+	}
+	for i, p := range res.Payments {
+		if expected := fmt.Sprintf("p%02d", i); p.PaymentID != expected {
+			t.Errorf("Expected Payment ID: %s in position %d. Got: %s\n", expected, i, p.PaymentID)
+		}
+	}
+
+	if fmt.Sprint(res.NotFound) != "[missing1 missing2]" {
+		t.Errorf("Expected not found: [missing1 missing2]. Got: %v\n", res.NotFound)
+	}
+	if maxBatch > 4 {
+		t.Errorf("Expected batches of at most 4 payment IDs. Got: %d\n", maxBatch)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent requests. Got: %d\n", maxInFlight)
+	}
+
+	// Partial failure
+	res, err = c.GetPaymentsBatch(context.Background(), []string{"p00", "p01", "fail", "p03", "p04", "missing1"}, &BatchOptions{BatchSize: 2})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || !errors.Is(err, ErrServer) {
+		t.Fatalf("Expected BatchError matching %v. Got: %v\n", ErrServer, err)
+	}
+	if fmt.Sprint(batchErr.FailedPaymentIDs()) != "[fail p03]" {
+		t.Errorf("Expected failed Payment IDs: [fail p03]. Got: %v\n", batchErr.FailedPaymentIDs())
+	}
+	if len(res.Payments) != 3 || fmt.Sprint(res.NotFound) != "[missing1]" {
+		t.Errorf("Expected 3 payments and not found [missing1]. Got: %d payments and not found %v\n", len(res.Payments), res.NotFound)
+	}
+	// Errors are in the order of the Payment IDs, whatever the order batches complete in
+	for i := 0; i < 5; i++ {
+		_, err = c.GetPaymentsBatch(context.Background(), []string{"fail1", "fail2", "fail3", "fail4", "fail5"}, &BatchOptions{BatchSize: 1, Concurrency: 5})
+		if !errors.As(err, &batchErr) || fmt.Sprint(batchErr.FailedPaymentIDs()) != "[fail1 fail2 fail3 fail4 fail5]" {
+			t.Fatalf("Expected failed Payment IDs in order. Got: %v\n", err)
+		}
+	}
+}
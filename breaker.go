@@ -0,0 +1,193 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by the methods of Client when the circuit breaker is open and the request was not sent.
+var ErrCircuitOpen = errors.New("DeroMerchant Client: circuit breaker is open")
+
+// CircuitState represents the state of the circuit breaker of a Client.
+type CircuitState int
+
+// States of the circuit breaker.
+const (
+	CircuitClosed   CircuitState = iota // Requests are sent.
+	CircuitOpen                         // Requests fail immediately with ErrCircuitOpen.
+	CircuitHalfOpen                     // A single probe request is sent to check whether the server recovered.
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions is a struct that holds the options of the circuit breaker.
+// CircuitBreakerOptions have to be passed as the CircuitBreaker field of ClientOptions.
+// The circuit opens after FailureThreshold consecutive failures (network errors and 5xx responses) and stays open for Cooldown.
+// It then turns half-open and closes again after SuccessThreshold consecutive successful probes.
+// If not provided, FailureThreshold defaults to 5, SuccessThreshold to 1 and Cooldown to 30 seconds.
+type CircuitBreakerOptions struct {
+	FailureThreshold int
+	SuccessThreshold int
+	Cooldown         time.Duration
+}
+
+// CircuitBreakerStats is a struct that holds the current state of the circuit breaker.
+type CircuitBreakerStats struct {
+	State                CircuitState
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	OpenedAt             time.Time // Time the circuit last opened. Zero if it never did.
+}
+
+const (
+	defaultFailureThreshold = 5
+	defaultSuccessThreshold = 1
+	defaultCooldown         = 30 * time.Second
+)
+
+type circuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	successThreshold int
+	cooldown         time.Duration
+
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	probing   bool
+
+	clock Clock
+}
+
+func newCircuitBreaker(o *CircuitBreakerOptions, clock Clock) *circuitBreaker {
+	b := &circuitBreaker{
+		failureThreshold: o.FailureThreshold,
+		successThreshold: o.SuccessThreshold,
+		cooldown:         o.Cooldown,
+		clock:            clock,
+	}
+
+	if b.failureThreshold < 1 {
+		b.failureThreshold = defaultFailureThreshold
+	}
+	if b.successThreshold < 1 {
+		b.successThreshold = defaultSuccessThreshold
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultCooldown
+	}
+
+	return b
+}
+
+// Allow returns ErrCircuitOpen if a request must not be sent.
+// Every nil return must be followed by a call to Record.
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		b.state = CircuitHalfOpen
+		b.successes = 0
+	}
+
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// Record reports the outcome of a request allowed by Allow.
+// A canceled request releases the half-open probe slot without changing the state of the circuit.
+func (b *circuitBreaker) Record(o requestOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	switch o {
+	case outcomeCanceled:
+		// The server was not checked
+	case outcomeSuccess:
+		b.failures = 0
+		b.successes++
+		if b.state == CircuitHalfOpen && b.successes >= b.successThreshold {
+			b.state = CircuitClosed
+		}
+	case outcomeFailure:
+		b.successes = 0
+		b.failures++
+		if b.state == CircuitHalfOpen || b.failures >= b.failureThreshold {
+			b.state = CircuitOpen
+			b.openedAt = b.clock.Now()
+		}
+	}
+}
+
+func (b *circuitBreaker) Stats() CircuitBreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := CircuitBreakerStats{
+		State:                b.state,
+		ConsecutiveFailures:  b.failures,
+		ConsecutiveSuccesses: b.successes,
+		OpenedAt:             b.openedAt,
+	}
+	if s.State == CircuitOpen && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		s.State = CircuitHalfOpen
+	}
+	return s
+}
+
+type requestOutcome int
+
+const (
+	outcomeSuccess requestOutcome = iota
+	outcomeFailure
+	outcomeCanceled
+)
+
+// outcomeOf returns how the outcome of a request counts for the circuit breaker.
+// Requests canceled by the caller are neither successes nor failures of the server.
+func outcomeOf(statusCode int, err error) requestOutcome {
+	switch {
+	case err != nil && statusCode == 0 && errors.Is(err, context.Canceled):
+		return outcomeCanceled
+	case err != nil && statusCode == 0, statusCode >= 500:
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+// CircuitBreakerStats returns the current state of the circuit breaker.
+// It returns the zero value (a closed circuit) if the Client was created without CircuitBreakerOptions.
+func (c *Client) CircuitBreakerStats() CircuitBreakerStats {
+	if c.breaker == nil {
+		return CircuitBreakerStats{}
+	}
+
+	return c.breaker.Stats()
+}
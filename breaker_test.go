@@ -0,0 +1,145 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		fail     = true
+		requests = 0
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		CircuitBreaker: &CircuitBreakerOptions{
+			FailureThreshold: 2,
+			Cooldown:         50 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	// Circuit opens after 2 consecutive failures
+	for i := 0; i < 2; i++ {
+		_, err = c.Ping()
+		if err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Expected server error. Got: %v\n", err)
+		}
+	}
+
+	if s := c.CircuitBreakerStats().State; s != CircuitOpen {
+		t.Fatalf("Expected circuit state: %s. Got: %s\n", CircuitOpen, s)
+	}
+
+	// Open circuit fails fast without sending requests
+	_, err = c.Ping()
+	if err != ErrCircuitOpen {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrCircuitOpen, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests to reach the server. Got: %d\n", requests)
+	}
+
+	// After cooldown, a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	if s := c.CircuitBreakerStats().State; s != CircuitHalfOpen {
+		t.Fatalf("Expected circuit state: %s. Got: %s\n", CircuitHalfOpen, s)
+	}
+
+	_, err = c.Ping()
+	if err == nil || err == ErrCircuitOpen {
+		t.Fatalf("Expected server error. Got: %v\n", err)
+	}
+	if s := c.CircuitBreakerStats().State; s != CircuitOpen {
+		t.Fatalf("Expected circuit state: %s. Got: %s\n", CircuitOpen, s)
+	}
+
+	// After cooldown, a successful probe closes the circuit
+	fail = false
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = c.Ping()
+	if err != nil {
+		t.Fatalf("Error not expected. Got: %v\n", err)
+	}
+	if s := c.CircuitBreakerStats().State; s != CircuitClosed {
+		t.Errorf("Expected circuit state: %s. Got: %s\n", CircuitClosed, s)
+	}
+}
+
+func TestCircuitBreakerCanceledProbe(t *testing.T) {
+	var (
+		fail    = true
+		blocked = make(chan struct{})
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		<-blocked // Hold the probe until the caller cancels it
+	}))
+	defer ts.Close()
+	defer close(blocked)
+
+	c, err := NewClient(&ClientOptions{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		CircuitBreaker: &CircuitBreakerOptions{
+			FailureThreshold: 1,
+			Cooldown:         20 * time.Millisecond,
+		},
+	}, WithBaseURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Ping()
+	if err == nil || err == ErrCircuitOpen {
+		t.Fatalf("Expected server error. Got: %v\n", err)
+	}
+
+	fail = false
+	time.Sleep(30 * time.Millisecond)
+
+	// The half-open probe is canceled by its caller
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err = c.PingWithContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error: %v. Got: %v\n", context.Canceled, err)
+	}
+
+	s := c.CircuitBreakerStats()
+	if s.State != CircuitHalfOpen || s.ConsecutiveSuccesses != 0 || s.ConsecutiveFailures != 1 {
+		t.Errorf("Expected canceled probe not to change the circuit. Got: %+v\n", s)
+	}
+
+	// The probe slot is released
+	if err := c.breaker.Allow(); err != nil {
+		t.Errorf("Expected a new probe to be allowed. Got: %v\n", err)
+	}
+}
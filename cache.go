@@ -0,0 +1,230 @@
+package deromerchant
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PaymentCache is a pluggable cache backend of CachedClient, storing Payments by Payment ID.
+// A ttl of 0 means the entry never expires. Implementations must be safe for concurrent use.
+type PaymentCache interface {
+	Get(paymentID string) (*Payment, bool)
+	Set(paymentID string, p *Payment, ttl time.Duration)
+	Delete(paymentID string)
+}
+
+// LRUCache is an in-memory PaymentCache holding up to a fixed number of Payments, evicting the least recently used ones.
+// Use NewLRUCache to create a new LRUCache.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	clock    Clock
+	ll       *list.List
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	paymentID string
+	payment   Payment
+	expiresAt time.Time
+}
+
+// NewLRUCache returns a new LRUCache holding up to capacity Payments. If capacity is less than 1, it defaults to 1000.
+func NewLRUCache(capacity int) *LRUCache {
+	return NewLRUCacheWithClock(capacity, systemClock{})
+}
+
+// NewLRUCacheWithClock is like NewLRUCache but the TTLs of the cached Payments are measured with clk.
+func NewLRUCacheWithClock(capacity int, clk Clock) *LRUCache {
+	if capacity < 1 {
+		capacity = 1000
+	}
+	if clk == nil {
+		clk = systemClock{}
+	}
+
+	return &LRUCache{
+		capacity: capacity,
+		clock:    clk,
+		ll:       list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached Payment with ID paymentID, if present and not expired.
+func (c *LRUCache) Get(paymentID string) (*Payment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[paymentID]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*lruEntry)
+	if !e.expiresAt.IsZero() && c.clock.Now().After(e.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.entries, paymentID)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return copyPayment(&e.payment), true
+}
+
+// Set caches a copy of p for ttl.
+func (c *LRUCache) Set(paymentID string, p *Payment, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &lruEntry{paymentID: paymentID, payment: *copyPayment(p)}
+	if ttl > 0 {
+		e.expiresAt = c.clock.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[paymentID]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.entries[paymentID] = c.ll.PushFront(e)
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).paymentID)
+	}
+}
+
+// Delete removes the Payment with ID paymentID from the cache.
+func (c *LRUCache) Delete(paymentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[paymentID]; ok {
+		c.ll.Remove(el)
+		delete(c.entries, paymentID)
+	}
+}
+
+// Len returns the number of Payments in the cache, including expired ones not evicted yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// CacheOptions is a struct that holds the options of a CachedClient.
+// PendingTTL is how long a pending Payment is cached, bounded by its remaining TTL. If not provided, it defaults to 5 seconds.
+type CacheOptions struct {
+	PendingTTL time.Duration
+}
+
+// CacheStats is a struct that holds the hit/miss counters of a CachedClient.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+const defaultPendingTTL = 5 * time.Second
+
+// CachedClient is a Client whose GetPayment responses are cached.
+// Payments with a final status (paid, expired, error) are cached indefinitely, pending ones briefly.
+// Other methods of Client are not cached.
+// Use NewCachedClient to create a new CachedClient.
+type CachedClient struct {
+	hits   uint64 // First fields of the struct to be 64-bit aligned for atomic operations.
+	misses uint64
+
+	*Client
+
+	cache      PaymentCache
+	pendingTTL time.Duration
+}
+
+// NewCachedClient returns a new CachedClient sending requests with c and caching Payments in cache.
+// If cache is nil, an LRUCache of default capacity using the Clock of c is used. o is optional.
+func NewCachedClient(c *Client, cache PaymentCache, o *CacheOptions) *CachedClient {
+	if cache == nil {
+		cache = NewLRUCacheWithClock(0, c.clockOrSystem())
+	}
+
+	cc := &CachedClient{
+		Client:     c,
+		cache:      cache,
+		pendingTTL: defaultPendingTTL,
+	}
+	if o != nil && o.PendingTTL > 0 {
+		cc.pendingTTL = o.PendingTTL
+	}
+
+	return cc
+}
+
+// ttl returns how long p can be cached, 0 meaning indefinitely. The second return value is false if p must not be cached.
+func (cc *CachedClient) ttl(p *Payment) (time.Duration, bool) {
+	if p.IsFinal() {
+		return 0, true
+	}
+
+	ttl := cc.pendingTTL
+	if remaining := time.Duration(p.TTL) * time.Minute; remaining < ttl {
+		ttl = remaining
+	}
+
+	return ttl, ttl > 0
+}
+
+// GetPayment is like Client.GetPayment but returns the cached Payment, if any.
+func (cc *CachedClient) GetPayment(paymentID string) (*Payment, error) {
+	return cc.GetPaymentWithContext(context.Background(), paymentID)
+}
+
+// GetPaymentWithContext is like Client.GetPaymentWithContext but returns the cached Payment, if any.
+func (cc *CachedClient) GetPaymentWithContext(ctx context.Context, paymentID string) (*Payment, error) {
+	if p, ok := cc.cache.Get(paymentID); ok {
+		atomic.AddUint64(&cc.hits, 1)
+		return p, nil
+	}
+	atomic.AddUint64(&cc.misses, 1)
+
+	p, err := cc.Client.GetPaymentWithContext(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil // Nothing to cache for a null response
+	}
+
+	if ttl, ok := cc.ttl(p); ok {
+		cc.cache.Set(paymentID, p, ttl)
+	}
+
+	return p, nil
+}
+
+// Invalidate removes the Payment with ID paymentID from the cache.
+func (cc *CachedClient) Invalidate(paymentID string) {
+	cc.cache.Delete(paymentID)
+}
+
+// HandleWebhookEvent invalidates the cached Payment updated by e. It should be called for every verified webhook request.
+func (cc *CachedClient) HandleWebhookEvent(e *PaymentUpdateEvent) {
+	if e == nil {
+		return
+	}
+
+	cc.Invalidate(e.PaymentID)
+}
+
+// Stats returns the hit/miss counters of the cache.
+func (cc *CachedClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&cc.hits),
+		Misses: atomic.LoadUint64(&cc.misses),
+	}
+}
@@ -0,0 +1,135 @@
+package deromerchant
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set("a", &Payment{PaymentID: "a"}, 0)
+	c.Set("b", &Payment{PaymentID: "b"}, 0)
+	c.Get("a") // a is now the most recently used
+	c.Set("c", &Payment{PaymentID: "c"}, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected least recently used payment to be evicted")
+	}
+	if p, ok := c.Get("a"); !ok || p.PaymentID != "a" {
+		t.Errorf("Expected payment a to be cached. Got: %v\n", p)
+	}
+
+	c.Set("d", &Payment{PaymentID: "d"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("d"); ok {
+		t.Error("Expected expired payment not to be returned")
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected deleted payment not to be returned")
+	}
+}
+
+func TestCachedClient(t *testing.T) {
+	requests := 0
+	statuses := map[string]string{
+		"paid":    PaymentStatusPaid,
+		"pending": PaymentStatusPending,
+		"expired": PaymentStatusPending, // Pending, with no time left
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		paymentID := strings.Split(r.URL.Path, "/")[2]
+		if paymentID == "null" {
+			w.Write([]byte("null"))
+			return
+		}
+		p := &Payment{PaymentID: paymentID, Status: statuses[paymentID], TTL: 60}
+		if paymentID == "expired" {
+			p.TTL = 0
+		}
+
+		resp, err := json.Marshal(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	cc := NewCachedClient(c, nil, &CacheOptions{PendingTTL: 20 * time.Millisecond})
+
+	tests := []struct {
+		paymentID        string
+		expectedRequests int
+	}{
+		{"paid", 1},
+		{"paid", 1},
+		{"pending", 2},
+		{"pending", 2},
+		{"expired", 3},
+		{"expired", 4},
+	}
+
+	for _, test := range tests {
+		p, err := cc.GetPayment(test.paymentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.PaymentID != test.paymentID {
+			t.Errorf("Expected Payment ID: %s. Got: %s\n", test.paymentID, p.PaymentID)
+		}
+		if requests != test.expectedRequests {
+			t.Errorf("Expected %d requests after getting payment %s. Got: %d\n", test.expectedRequests, test.paymentID, requests)
+		}
+	}
+
+	// Pending payments expire after PendingTTL
+	time.Sleep(30 * time.Millisecond)
+	_, err = cc.GetPayment("pending")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 5 {
+		t.Errorf("Expected pending payment to expire from cache. Got %d requests\n", requests)
+	}
+
+	// Webhooks invalidate cached payments
+	cc.HandleWebhookEvent(&PaymentUpdateEvent{PaymentID: "paid", Status: PaymentStatusPaid})
+	_, err = cc.GetPayment("paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 6 {
+		t.Errorf("Expected invalidated payment to be fetched again. Got %d requests\n", requests)
+	}
+
+	if s := cc.Stats(); s.Hits != 2 || s.Misses != 6 {
+		t.Errorf("Expected 2 hits and 6 misses. Got: %+v\n", s)
+	}
+
+	// Null responses are returned as is and not cached
+	for i := 0; i < 2; i++ {
+		p, err := cc.GetPayment("null")
+		if err != nil || p != nil {
+			t.Errorf("Expected nil Payment and no error. Got: %v, %v\n", p, err)
+		}
+	}
+	if requests != 8 {
+		t.Errorf("Expected null response not to be cached. Got %d requests\n", requests)
+	}
+}
@@ -2,8 +2,10 @@ package deromerchant
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -17,43 +19,93 @@ import (
 // Client also has methods that make use of such information to perform said requests and return the response (or error) in fitting structs.
 // Use NewClient to create a new Client.
 type Client struct {
+	skew int64 // Clock skew in nanoseconds, accessed atomically. First field for 64-bit alignment.
+
 	scheme     string
 	host       string
 	apiVersion string
 	baseURL    string
+	userAgent  string
 	HTTPClient *http.Client
 
-	apiKey    string
-	secretKey string
+	apiKey      string
+	secretKey   *SecretKey
+	credentials CredentialsProvider
+
+	signatureVersion int
+
+	tracer  Tracer
+	limiter *rateLimiter
+	breaker *circuitBreaker
+	flights *flightGroup
+	clock   Clock
+	random  io.Reader
+
+	decoding     *DecodeOptions
+	responseHook func(resp *Response)
+	interceptors []Interceptor
 }
 
 // ClientOptions is a struct that holds the required options for the initialization of a new Client.
 // ClientOptions have to be passed as an argument of the NewClient function.
 // Scheme, Host and APIVersion are optional. If not provided, they will be filled with default values.
+// Tracer is optional. If not provided, requests will not be traced.
+// RateLimit is optional. If not provided, requests will not be limited client-side.
+// CircuitBreaker is optional. If not provided, requests will always be sent.
+// SignatureVersion is optional. If not provided, SignatureV1 is used.
 type ClientOptions struct {
 	Scheme     string
 	Host       string
 	APIVersion string
 
-	APIKey    string
-	SecretKey string
+	APIKey           string
+	SecretKey        string
+	SignatureVersion int
+
+	Tracer         Tracer
+	RateLimit      *RateLimitOptions
+	CircuitBreaker *CircuitBreakerOptions
 }
 
 const (
 	defaultScheme     = "https"
 	defaultHost       = "merchant.dero.io"
 	defaultAPIVersion = "v1"
+	defaultUserAgent  = "DeroMerchant_Client_Golang/1.0"
+	defaultTimeout    = time.Second * 10
 )
 
 // NewClient returns a new Client.
 // ClientOptions API Key and Secret Key are required. Scheme, Host and APIVersion will be filled with default values if not provided.
-func NewClient(o *ClientOptions) (*Client, error) {
+// Keys are checked up front: an error is returned if the API Key is empty or not a valid header value,
+// or if the Secret Key is empty (and not set by WithSecretKey) or invalid (ErrInvalidSecretKey).
+// Keys can only be left empty if WithCredentialsProvider is passed.
+// Functional options are applied in order after ClientOptions.
+func NewClient(o *ClientOptions, opts ...Option) (*Client, error) {
+	if o == nil {
+		o = &ClientOptions{}
+	}
+
+	err := validateAPIKey(o.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var secretKey *SecretKey
+	if o.SecretKey != "" {
+		secretKey, err = ParseSecretKey(o.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	c := &Client{
 		scheme:     o.Scheme,
 		host:       o.Host,
 		apiVersion: o.APIVersion,
 		apiKey:     o.APIKey,
-		secretKey:  o.SecretKey,
+		secretKey:  secretKey,
+		tracer:     o.Tracer,
 	}
 
 	if c.scheme == "" {
@@ -68,20 +120,69 @@ func NewClient(o *ClientOptions) (*Client, error) {
 
 	c.baseURL = fmt.Sprintf("%s://%s/api/%s", c.scheme, c.host, c.apiVersion)
 
-	_, err := url.ParseRequestURI(c.baseURL)
+	_, err = url.ParseRequestURI(c.baseURL)
 	if err != nil {
 		return nil, err
 	}
 
+	switch o.SignatureVersion {
+	case 0, SignatureV1:
+	case SignatureV2:
+		c.signatureVersion = SignatureV2
+	default:
+		return nil, fmt.Errorf("DeroMerchant Client: unsupported signature version %d", o.SignatureVersion)
+	}
+
 	c.HTTPClient = &http.Client{
-		Timeout: time.Second * 10,
+		Timeout: defaultTimeout,
+	}
+	c.flights = &flightGroup{}
+
+	for _, opt := range opts {
+		err := opt(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.credentials == nil {
+		if c.apiKey == "" {
+			return nil, errors.New("DeroMerchant Client: API key is required")
+		}
+		if c.secretKey == nil {
+			return nil, errors.New("DeroMerchant Client: secret key is required")
+		}
+	}
+
+	// Created after options are applied, to use the Clock of WithClock
+	if o.RateLimit != nil {
+		c.limiter = newRateLimiter(o.RateLimit, c.clockOrSystem())
+	}
+	if o.CircuitBreaker != nil {
+		c.breaker = newCircuitBreaker(o.CircuitBreaker, c.clockOrSystem())
 	}
 
 	return c, nil
 }
 
+func validateAPIKey(apiKey string) error {
+	for _, r := range apiKey {
+		if r <= ' ' || r >= 0x7f {
+			return errors.New("DeroMerchant Client: invalid API key: unexpected character")
+		}
+	}
+
+	return nil
+}
+
 // NewRequest returns a new request ready to be sent with SendRequest or SendSignedRequest.
 func (c *Client) NewRequest(method, endpoint string, queryParams map[string]interface{}, payload interface{}) (*http.Request, error) {
+	return c.NewRequestWithContext(context.Background(), method, endpoint, queryParams, payload)
+}
+
+// NewRequestWithContext is like NewRequest but the returned request carries ctx.
+// If ctx carries a TraceContext, it is propagated in the traceparent and tracestate headers.
+func (c *Client) NewRequestWithContext(ctx context.Context, method, endpoint string, queryParams map[string]interface{}, payload interface{}) (*http.Request, error) {
 	url := c.baseURL + endpoint
 
 	var body io.Reader
@@ -94,7 +195,7 @@ func (c *Client) NewRequest(method, endpoint string, queryParams map[string]inte
 	}
 
 	method = strings.ToUpper(method)
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -106,12 +207,17 @@ func (c *Client) NewRequest(method, endpoint string, queryParams map[string]inte
 	}
 	req.URL.RawQuery = q.Encode()
 
-	req.Header.Set("User-Agent", "DeroMerchant_Client_Golang/1.0")
+	userAgent := c.userAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("X-API-Key", c.apiKey)
 	if payload != nil {
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Accept", "application/json")
 	}
+	InjectTraceContext(ctx, req.Header)
 
 	return req, nil
 }
@@ -121,63 +227,103 @@ type errorResponse struct {
 }
 
 // SendRequest sends a request to the API.
+// Unsuccessful responses are returned as an APIError, or as an HTTPError if the response does not contain an API error object.
+// A span is created around the request with the Tracer of the Client.
+// If the Client uses SignatureV2, the request is signed as if sent with SendSignedRequest.
 func (c *Client) SendRequest(req *http.Request, respBody interface{}) error {
+	return c.doIntercepted(req, respBody, c.signatureVersion == SignatureV2)
+}
+
+func (c *Client) send(req *http.Request, respBody interface{}) error {
+	req, span := c.startSpan(req)
+
+	statusCode, err := c.sendRequest(req, respBody)
+	if statusCode != 0 {
+		span.SetAttribute("http.status_code", statusCode)
+	}
+	span.End(err)
+
+	return err
+}
+
+func (c *Client) sendRequest(req *http.Request, respBody interface{}) (int, error) {
+	if c.limiter != nil {
+		err := c.limiter.Wait(req.Context())
+		if err != nil {
+			return 0, fmt.Errorf("DeroMerchant Client: waiting for rate limiter: %w", err)
+		}
+	}
+
+	if c.breaker != nil {
+		err := c.breaker.Allow()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	start := c.now()
 	resp, err := c.HTTPClient.Do(req)
+	if c.breaker != nil {
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		c.breaker.Record(outcomeOf(statusCode, err))
+	}
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("DeroMerchant Client: %w", err)
 	}
 	defer resp.Body.Close()
 
+	c.observeDate(resp)
+	if c.limiter != nil {
+		c.limiter.Observe(resp)
+	}
+
 	b, err := ioutil.ReadAll(resp.Body)
+	c.observeResponse(req, resp, b, c.now().Sub(start))
 	if err != nil {
-		return err
+		return resp.StatusCode, fmt.Errorf("DeroMerchant Client: error reading response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		var errResp errorResponse
 		err := json.Unmarshal(b, &errResp)
-		if err != nil {
-			if resp.StatusCode == http.StatusNotFound {
-				return fmt.Errorf("DeroMerchant Client: error 404: page %s not found", req.URL.String())
-			}
-
-			return fmt.Errorf("DeroMerchant Client: error %d returned by %s", resp.StatusCode, req.URL.String())
+		if err != nil || errResp.Error == nil {
+			return resp.StatusCode, newHTTPError(resp, b)
 		}
 
-		return errResp.Error
+		return resp.StatusCode, errResp.Error
 	}
 
 	if respBody != nil {
-		err = json.Unmarshal(b, respBody)
+		err = decodeJSON(b, respBody, c.decoding)
 		if err != nil {
-			return err
+			return resp.StatusCode, &decodeError{err}
 		}
 	}
 
-	return nil
+	return resp.StatusCode, nil
 }
 
 // SendSignedRequest sends a signed request to the API.
 // The signature is generated using the Secret Key to create a MAC of the request body.
 // Signature is then sent along with the request in the X-Sginature header.
+// If the Client uses SignatureV2, a MAC of method, path, query, timestamp, nonce and body is also sent in the SignatureV2 headers.
 func (c *Client) SendSignedRequest(req *http.Request, respBody interface{}) error {
-	if req.Body != nil {
-		b, err := req.GetBody()
-		if err != nil {
-			return err
-		}
-
-		body, err := ioutil.ReadAll(b)
-		if err != nil {
-			return err
-		}
+	return c.doIntercepted(req, respBody, true)
+}
 
-		key, err := hex.DecodeString(c.secretKey)
+// signRequest signs req with key, using the signature scheme of the Client.
+// The SignatureV1 MAC of the body is always set, as the server checks it, and SignatureV2 headers are added on top of it.
+func (c *Client) signRequest(req *http.Request, key *SecretKey) error {
+	if req.Body != nil {
+		body, err := readBody(req)
 		if err != nil {
 			return err
 		}
 
-		s, err := signMessage(body, key)
+		s, err := key.sign(body)
 		if err != nil {
 			return err
 		}
@@ -186,7 +332,11 @@ func (c *Client) SendSignedRequest(req *http.Request, respBody interface{}) erro
 		req.Header.Set("X-Signature", signature)
 	}
 
-	return c.SendRequest(req, respBody)
+	if c.signatureVersion == SignatureV2 {
+		return signRequestV2(req, key, c.now(), c.randomReader())
+	}
+
+	return nil
 }
 
 // GetPayHelperURL returns the URL of the Pay helper page of paymentID.
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -34,7 +35,7 @@ func TestNewClient(t *testing.T) {
 				host:       defaultHost,
 				apiVersion: defaultAPIVersion,
 				apiKey:     apiKey,
-				secretKey:  secretKey,
+				secretKey:  mustParseSecretKey(t, secretKey),
 			},
 			expectError: false,
 		},
@@ -52,7 +53,7 @@ func TestNewClient(t *testing.T) {
 				host:       "localhost:8080",
 				apiVersion: "v1",
 				apiKey:     apiKey,
-				secretKey:  secretKey,
+				secretKey:  mustParseSecretKey(t, secretKey),
 			},
 			expectError: false,
 		},
@@ -89,10 +90,16 @@ func TestNewClient(t *testing.T) {
 				t.FailNow()
 			}
 
+			if !c.secretKey.Equal(test.expectedClient.secretKey) {
+				t.Error("Expected Client secret key to match ClientOptions secret key")
+			}
+
 			test.expectedClient.baseURL = c.baseURL
 			test.expectedClient.HTTPClient = c.HTTPClient
+			test.expectedClient.secretKey = c.secretKey
+			test.expectedClient.flights = c.flights
 
-			if *c != *test.expectedClient {
+			if !reflect.DeepEqual(c, test.expectedClient) {
 				t.Errorf("\nExpected Client:\n%+v\nGot:\n%+v\n", *&test.expectedClient, *c)
 			}
 		}
@@ -395,12 +402,7 @@ func TestSendSignedRequest(t *testing.T) {
 			defer r.Body.Close()
 			body, err := ioutil.ReadAll(r.Body)
 
-			key, err := hex.DecodeString(c.secretKey)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			s, err := signMessage(body, key)
+			s, err := c.secretKey.sign(body)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -456,6 +458,15 @@ func TestGetPayHelperURL(t *testing.T) {
 	}
 }
 
+func mustParseSecretKey(t *testing.T, s string) *SecretKey {
+	k, err := ParseSecretKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
 func sendErrorResponse(w http.ResponseWriter, code int, message string) error {
 	resp := &errorResponse{
 		Error: &APIError{
@@ -0,0 +1,141 @@
+package deromerchant
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the current time and waits for time to pass.
+// Use WithClock to make a Client use a Clock other than the system clock (e.g. deromerchanttest.FakeClock in tests).
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock makes the Client read the current time from clk instead of the system clock.
+// It is used for TTL math, request signing timestamps, the rate limiter and the circuit breaker,
+// as well as by the default cache of NewCachedClient, the Webhook Secret Keys of a StoreRegistry and the reports of a Reconciler.
+func WithClock(clk Clock) Option {
+	return func(c *Client) error {
+		if clk == nil {
+			return errors.New("DeroMerchant Client: nil clock")
+		}
+
+		c.clock = clk
+		return nil
+	}
+}
+
+// WithRandom makes the Client read random bytes (e.g. the nonces of SignatureV2) from r instead of crypto/rand.
+// r must be a cryptographically secure source outside of tests.
+func WithRandom(r io.Reader) Option {
+	return func(c *Client) error {
+		if r == nil {
+			return errors.New("DeroMerchant Client: nil random source")
+		}
+
+		c.random = r
+		return nil
+	}
+}
+
+func (c *Client) clockOrSystem() Clock {
+	if c.clock == nil {
+		return systemClock{}
+	}
+	return c.clock
+}
+
+// now returns the current time of the Client's Clock.
+func (c *Client) now() time.Time {
+	return c.clockOrSystem().Now()
+}
+
+func (c *Client) randomReader() io.Reader {
+	if c.random == nil {
+		return rand.Reader
+	}
+	return c.random
+}
+
+// ClockSkew returns how far the clock of the DERO Merchant server is ahead of the Client's Clock (negative if behind).
+// It is measured from the Date header of the last response received, so it is only accurate to about a second.
+func (c *Client) ClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.skew))
+}
+
+// observeDate updates the clock skew of the Client from the Date header of resp.
+func (c *Client) observeDate(resp *http.Response) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	atomic.StoreInt64(&c.skew, int64(date.Sub(c.now())))
+}
+
+// stampPayments records on ps the server time they were fetched at, which their TTL is relative to.
+func (c *Client) stampPayments(ps ...*Payment) {
+	skew := c.ClockSkew()
+	fetchedAt := c.now().Add(skew)
+
+	for _, p := range ps {
+		if p != nil {
+			p.fetchedAt = fetchedAt
+			p.skew = skew
+		}
+	}
+}
+
+// ExpiresAt returns the time at which the Payment expires, on the clock of the DERO Merchant server.
+// TTL is the number of minutes left when the Payment was fetched, so ExpiresAt is only accurate to about a minute.
+// The time a Payment was fetched at is only known for Payments returned by the methods of a Client, and it is not marshalled:
+// for other Payments (e.g. unmarshalled from JSON, such as a JSON Lines export or a user's own cache), ExpiresAt assumes
+// they were fetched when they were created, which is only right for Payments fetched right after CreatePayment.
+func (p *Payment) ExpiresAt() time.Time {
+	fetchedAt := p.fetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = p.CreationTime
+	}
+
+	return fetchedAt.Add(time.Duration(p.TTL) * time.Minute)
+}
+
+// Remaining returns how long is left before the Payment expires, or 0 if it already expired.
+// now is read from the local clock and corrected by the clock skew measured by the Client that fetched the Payment.
+// Like the time it was fetched at, that skew is lost when a Payment is marshalled: for Payments not returned by a Client,
+// no correction is applied, so pass now.Add(c.ClockSkew()) to correct it with the skew measured by Client c.
+func (p *Payment) Remaining(now time.Time) time.Duration {
+	d := p.ExpiresAt().Sub(now.Add(p.skew))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// IsExpired returns whether the Payment expired at now (see Remaining).
+// Paid Payments never expire, while Payments with status expired always are.
+func (p *Payment) IsExpired(now time.Time) bool {
+	switch p.Status {
+	case PaymentStatusExpired:
+		return true
+	case PaymentStatusPaid, PaymentStatusError:
+		return false
+	default:
+		return p.Remaining(now) == 0
+	}
+}
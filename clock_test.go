@@ -0,0 +1,193 @@
+package deromerchant
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func TestPaymentExpiry(t *testing.T) {
+	local := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := local.Add(90 * time.Second) // Server clock is 90 seconds ahead
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", server.Format(http.TimeFormat))
+
+		resp, err := json.Marshal(&Payment{
+			PaymentID:    "a",
+			Status:       PaymentStatusPending,
+			CreationTime: server.Add(-5 * time.Minute),
+			TTL:          10, // Minutes left
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithBaseURL(ts.URL), WithClock(fixedClock(local)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.GetPayment("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if skew := c.ClockSkew(); skew != 90*time.Second {
+		t.Errorf("Expected clock skew: 1m30s. Got: %v\n", skew)
+	}
+	if expiresAt := p.ExpiresAt(); !expiresAt.Equal(server.Add(10 * time.Minute)) {
+		t.Errorf("Expected expiry: %v. Got: %v\n", server.Add(10*time.Minute), expiresAt)
+	}
+
+	tests := []struct {
+		now               time.Time
+		expectedRemaining time.Duration
+		expectedExpired   bool
+	}{
+		{now: local, expectedRemaining: 10 * time.Minute, expectedExpired: false},
+		{now: local.Add(9 * time.Minute), expectedRemaining: time.Minute, expectedExpired: false},
+		{now: local.Add(10 * time.Minute), expectedRemaining: 0, expectedExpired: true},
+		{now: local.Add(time.Hour), expectedRemaining: 0, expectedExpired: true},
+	}
+
+	for _, test := range tests {
+		if remaining := p.Remaining(test.now); remaining != test.expectedRemaining {
+			t.Errorf("Expected remaining at %v: %v. Got: %v\n", test.now, test.expectedRemaining, remaining)
+		}
+		if expired := p.IsExpired(test.now); expired != test.expectedExpired {
+			t.Errorf("Expected expired at %v: %t. Got: %t\n", test.now, test.expectedExpired, expired)
+		}
+	}
+
+	p.Status = PaymentStatusPaid
+	if p.IsExpired(local.Add(time.Hour)) {
+		t.Error("Paid payment not expected to expire")
+	}
+
+	// Payments not fetched by a Client expire TTL minutes after creation
+	unfetched := &Payment{Status: PaymentStatusPending, CreationTime: local, TTL: 15}
+	if remaining := unfetched.Remaining(local.Add(5 * time.Minute)); remaining != 10*time.Minute {
+		t.Errorf("Expected remaining: 10m. Got: %v\n", remaining)
+	}
+}
+
+// manualClock is a Clock whose time only moves when set.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestClockInjection(t *testing.T) {
+	epoch := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	clk := &manualClock{now: epoch}
+
+	// LRUCache TTLs
+	cache := NewLRUCacheWithClock(10, clk)
+	cache.Set("a", &Payment{PaymentID: "a"}, time.Minute)
+	clk.Advance(59 * time.Second)
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("Expected Payment to be cached before its TTL on the clock")
+	}
+	clk.Advance(2 * time.Second)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Expected Payment to expire after its TTL on the clock")
+	}
+
+	// WebhookKeySet time windows and LastUsed
+	clk.now = epoch
+	set, err := NewWebhookKeySetWithClock(clk, WebhookKey{ID: "k", Key: mustParseSecretKey(t, secretKey), NotAfter: epoch.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &PaymentUpdateEvent{PaymentID: "a", Status: PaymentStatusPaid}
+	req, err := createWebhookRequest("http://localhost/webhook", e, secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Verify(req); err != nil {
+		t.Fatal(err)
+	}
+	if lastUsed, _ := set.LastUsed("k"); !lastUsed.Equal(epoch) {
+		t.Errorf("Expected LastUsed: %v. Got: %v\n", epoch, lastUsed)
+	}
+
+	clk.Advance(2 * time.Minute)
+	req, err = createWebhookRequest("http://localhost/webhook", e, secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Verify(req); err != ErrInvalidSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSignature, err)
+	}
+
+	// Request signatures checked against a given time
+	key := mustParseSecretKey(t, secretKey)
+	signed := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	err = signRequestV2(signed, key, epoch, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRequestSignatureAt(signed, key, time.Minute, epoch.Add(30*time.Second)); err != nil {
+		t.Errorf("Expected valid signature. Got: %v\n", err)
+	}
+	if err := VerifyRequestSignatureAt(signed, key, time.Minute, epoch.Add(2*time.Minute)); err != ErrSignatureExpired {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrSignatureExpired, err)
+	}
+
+	// Default cache of CachedClient and reports of Reconciler use the Clock of the Client
+	clk.now = epoch
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc := NewCachedClient(c, nil, nil)
+	if cache, ok := cc.cache.(*LRUCache); !ok || cache.clock != Clock(clk) {
+		t.Error("Expected default cache of CachedClient to use the Clock of the Client")
+	}
+
+	report, err := NewReconciler(c).Reconcile(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.GeneratedAt.Equal(epoch) {
+		t.Errorf("Expected report generated at: %v. Got: %v\n", epoch, report.GeneratedAt)
+	}
+}
@@ -0,0 +1,119 @@
+// Command deromerchant-mock runs a fake DERO Merchant server locally, to be used as a stand-in for the real one during development and QA.
+//
+// It serves /ping, /payment and /payments under /api/v1, checks the X-API-Key and X-Signature headers
+// like the DERO Merchant server, runs the scenarios of a JSON or YAML file and sends signed webhooks.
+//
+// Usage:
+//
+//	deromerchant-mock -addr :8080 -api-key KEY -secret-key HEX [-webhook-url URL -webhook-secret-key HEX] [-scenarios scenarios.json]
+//
+// Keys not provided are generated and printed. API Key and Secret Key can also be set with the
+// DERO_MERCHANT_API_KEY and DERO_MERCHANT_SECRET_KEY environment variables.
+// The format of the scenarios file is documented by deromerchanttest.LoadScenarios (JSON) and deromerchanttest.LoadScenariosYAML (.yaml or .yml files).
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+	"github.com/peppinux/dero-merchant-go-sdk/deromerchanttest"
+)
+
+func main() {
+	var (
+		addr             = flag.String("addr", ":8080", "address to listen on")
+		apiKey           = flag.String("api-key", os.Getenv(deromerchant.EnvAPIKey), "API Key (generated if empty)")
+		secretKey        = flag.String("secret-key", os.Getenv(deromerchant.EnvSecretKey), "hex encoded Secret Key (generated if empty)")
+		webhookURL       = flag.String("webhook-url", "", "URL webhooks are sent to (no webhooks if empty)")
+		webhookSecretKey = flag.String("webhook-secret-key", "", "hex encoded Webhook Secret Key (generated if empty)")
+		scenarios        = flag.String("scenarios", "", "JSON or YAML scenarios file")
+		ttl              = flag.Duration("ttl", deromerchanttest.DefaultPaymentTTL, "time payments stay pending")
+		quiet            = flag.Bool("quiet", false, "do not log requests and webhooks")
+	)
+	flag.Parse()
+
+	generate(apiKey, "API Key")
+	generate(secretKey, "Secret Key")
+	if *webhookURL != "" {
+		generate(webhookSecretKey, "Webhook Secret Key")
+	}
+
+	o := &deromerchanttest.ServerOptions{
+		APIKey:           *apiKey,
+		SecretKey:        *secretKey,
+		WebhookURL:       *webhookURL,
+		WebhookSecretKey: *webhookSecretKey,
+		PaymentTTL:       *ttl,
+	}
+	if !*quiet {
+		o.Logf = log.Printf
+	}
+
+	if *scenarios != "" {
+		var err error
+		o.Scenarios, err = deromerchanttest.LoadScenarioFile(*scenarios)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Loaded %d scenarios from %s", len(o.Scenarios), *scenarios)
+	}
+
+	s, err := deromerchanttest.NewServer(o)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{
+		Addr:    *addr,
+		Handler: s,
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("Serving DERO Merchant API on %s%s", *addr, deromerchanttest.APIPrefix)
+	err = srv.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	<-shutdown // ListenAndServe returns as soon as Shutdown starts, wait for the requests in flight
+	s.Close()
+}
+
+// generate sets key to a random hex encoded 32 bytes key if it is empty, and prints it.
+func generate(key *string, name string) {
+	if *key != "" {
+		return
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	*key = hex.EncodeToString(b)
+
+	log.Printf("%s: %s", name, *key)
+}
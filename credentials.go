@@ -0,0 +1,281 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Credentials holds the API Key and Secret Key of a store.
+type Credentials struct {
+	APIKey    string
+	SecretKey *SecretKey
+}
+
+// CredentialsProvider provides the credentials used by a Client. It is called for each request.
+// Credentials returns the credentials in order of preference: the first ones are used to send the request.
+// During a key rotation, the second ones are used to send the request again if the server answers 401 Unauthorized or 403 Forbidden.
+// Further credentials are ignored.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) ([]Credentials, error)
+}
+
+var (
+	// ErrNoCredentials is returned when a CredentialsProvider provides no credentials.
+	ErrNoCredentials = errors.New("DeroMerchant Client: no credentials provided")
+	// ErrInvalidCredentials is returned when a CredentialsProvider provides credentials with an empty or invalid API Key or a nil Secret Key.
+	ErrInvalidCredentials = errors.New("DeroMerchant Client: invalid credentials provided")
+)
+
+// WithCredentialsProvider makes the Client get its credentials from p for each request, instead of the API Key and Secret Key of ClientOptions.
+func WithCredentialsProvider(p CredentialsProvider) Option {
+	return func(c *Client) error {
+		if p == nil {
+			return errors.New("DeroMerchant Client: nil credentials provider")
+		}
+
+		c.credentials = p
+		return nil
+	}
+}
+
+// StaticCredentials is a CredentialsProvider that always provides the same credentials.
+type StaticCredentials []Credentials
+
+// Credentials returns s.
+func (s StaticCredentials) Credentials(ctx context.Context) ([]Credentials, error) {
+	if len(s) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	return s, nil
+}
+
+// parseCredentials returns the Credentials of the non-empty API Keys in apiKeys, with the Secret Keys at the same index in secretKeys.
+func parseCredentials(apiKeys, secretKeys []string) ([]Credentials, error) {
+	var creds []Credentials
+	for i, apiKey := range apiKeys {
+		if apiKey == "" {
+			continue
+		}
+
+		err := validateAPIKey(apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		secretKey, err := ParseSecretKey(secretKeys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		creds = append(creds, Credentials{APIKey: apiKey, SecretKey: secretKey})
+	}
+
+	if len(creds) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	return creds, nil
+}
+
+// Environment variables read by EnvCredentials, holding the credentials that replace the current ones during a key rotation.
+const (
+	EnvNextAPIKey    = "DERO_MERCHANT_NEXT_API_KEY"
+	EnvNextSecretKey = "DERO_MERCHANT_NEXT_SECRET_KEY"
+)
+
+// EnvCredentials is a CredentialsProvider that reads the credentials from the environment for each request,
+// so they can be changed without creating a new Client.
+// Current credentials are read from DERO_MERCHANT_API_KEY and DERO_MERCHANT_SECRET_KEY,
+// next credentials (optional, used during a rotation) from DERO_MERCHANT_NEXT_API_KEY and DERO_MERCHANT_NEXT_SECRET_KEY.
+type EnvCredentials struct {
+	mu    sync.Mutex
+	env   [4]string
+	creds []Credentials
+}
+
+// Credentials returns the credentials currently set in the environment.
+func (e *EnvCredentials) Credentials(ctx context.Context) ([]Credentials, error) {
+	env := [4]string{
+		os.Getenv(EnvAPIKey),
+		os.Getenv(EnvSecretKey),
+		os.Getenv(EnvNextAPIKey),
+		os.Getenv(EnvNextSecretKey),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.creds != nil && env == e.env {
+		return e.creds, nil
+	}
+
+	creds, err := parseCredentials([]string{env[0], env[2]}, []string{env[1], env[3]})
+	if err != nil {
+		return nil, err
+	}
+
+	e.env = env
+	e.creds = creds
+	return creds, nil
+}
+
+// FileCredentials is a CredentialsProvider that reads the credentials from a JSON file, reloaded whenever it is modified.
+// If the file can not be reloaded (e.g. it is read in the middle of a rewrite), the last credentials read are kept until it can.
+// The file holds an array of objects with apiKey and secretKey fields, in order of preference. Example:
+//
+//	[{"apiKey": "CURRENT_API_KEY", "secretKey": "CURRENT_SECRET_KEY"}, {"apiKey": "NEXT_API_KEY", "secretKey": "NEXT_SECRET_KEY"}]
+//
+// Use NewFileCredentials to create a new FileCredentials.
+type FileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   []Credentials
+}
+
+type fileCredentialsEntry struct {
+	APIKey    string `json:"apiKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// NewFileCredentials returns a new FileCredentials reading from the file at path. The file is read once to check it is valid.
+func NewFileCredentials(path string) (*FileCredentials, error) {
+	f := &FileCredentials{path: path}
+
+	_, err := f.Credentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Credentials returns the credentials in the file, reloading it if it was modified since last read.
+func (f *FileCredentials) Credentials(ctx context.Context) ([]Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	creds, err := f.reload()
+	if err != nil {
+		if f.creds != nil {
+			return f.creds, nil // Keep the last good credentials
+		}
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// reload must be called with f.mu held.
+func (f *FileCredentials) reload() ([]Credentials, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.creds != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.creds, nil
+	}
+
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []fileCredentialsEntry
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]string, len(entries))
+	secretKeys := make([]string, len(entries))
+	for i, e := range entries {
+		apiKeys[i] = e.APIKey
+		secretKeys[i] = e.SecretKey
+	}
+
+	creds, err := parseCredentials(apiKeys, secretKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.creds = creds
+	return creds, nil
+}
+
+// currentCredentials returns the credentials to send a request with, in order of preference.
+func (c *Client) currentCredentials(ctx context.Context) ([]Credentials, error) {
+	if c.credentials == nil {
+		return []Credentials{{APIKey: c.apiKey, SecretKey: c.secretKey}}, nil
+	}
+
+	creds, err := c.credentials.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) == 0 {
+		return nil, ErrNoCredentials
+	}
+	if len(creds) > 2 {
+		creds = creds[:2]
+	}
+
+	for i, cred := range creds {
+		if cred.APIKey == "" || validateAPIKey(cred.APIKey) != nil || cred.SecretKey == nil {
+			return nil, fmt.Errorf("%w: credentials %d have an empty or invalid API Key or a nil Secret Key", ErrInvalidCredentials, i)
+		}
+	}
+
+	return creds, nil
+}
+
+// do sets the credentials of req, signs it if signed is true, and sends it.
+// If the server answers 401 or 403 and the CredentialsProvider of the Client provided next credentials, req is sent again with those.
+func (c *Client) do(req *http.Request, respBody interface{}, signed bool) error {
+	creds, err := c.currentCredentials(req.Context())
+	if err != nil {
+		return err
+	}
+
+	for i, cred := range creds {
+		if i > 0 {
+			if req.Body != nil && req.GetBody == nil {
+				return fmt.Errorf("DeroMerchant Client: request not sent again with next credentials because its body can not be replayed: %w", err)
+			}
+			if req.GetBody != nil {
+				req.Body, err = req.GetBody()
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		req.Header.Set("X-API-Key", cred.APIKey)
+		if signed {
+			err = c.signRequest(req, cred.SecretKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = c.send(req, respBody)
+		if !errors.Is(err, ErrUnauthorized) && !errors.Is(err, ErrForbidden) {
+			return err
+		}
+	}
+
+	return err
+}
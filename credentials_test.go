@@ -0,0 +1,228 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsRotation(t *testing.T) {
+	requests := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("X-API-Key") != validAPIKey {
+			err := sendErrorResponse(w, http.StatusForbidden, "Forbidden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := mustParseSecretKey(t, validSecretKey).sign(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Header.Get("X-Signature") != hex.EncodeToString(s) {
+			err := sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"currency":"DERO","currencyAmount":1}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		creds            StaticCredentials
+		expectedRequests int
+		expectError      bool
+	}{
+		// Current credentials are valid
+		{StaticCredentials{{validAPIKey, mustParseSecretKey(t, validSecretKey)}, {invalidAPIKey, mustParseSecretKey(t, invalidSecretKey)}}, 1, false},
+		// Current API key was rotated: retry with next credentials
+		{StaticCredentials{{invalidAPIKey, mustParseSecretKey(t, invalidSecretKey)}, {validAPIKey, mustParseSecretKey(t, validSecretKey)}}, 2, false},
+		// Current Secret Key was rotated: retry with next credentials
+		{StaticCredentials{{validAPIKey, mustParseSecretKey(t, invalidSecretKey)}, {validAPIKey, mustParseSecretKey(t, validSecretKey)}}, 2, false},
+		// No valid credentials: retry only once
+		{StaticCredentials{{invalidAPIKey, mustParseSecretKey(t, validSecretKey)}, {invalidAPIKey, mustParseSecretKey(t, validSecretKey)}, {validAPIKey, mustParseSecretKey(t, validSecretKey)}}, 2, true},
+		// Invalid credentials are rejected before any request is sent
+		{StaticCredentials{{validAPIKey, nil}}, 0, true},
+		{StaticCredentials{{"", mustParseSecretKey(t, validSecretKey)}}, 0, true},
+		{StaticCredentials{{validAPIKey, mustParseSecretKey(t, validSecretKey)}, {validAPIKey, nil}}, 0, true},
+	}
+
+	for _, test := range tests {
+		c, err := NewClient(nil, WithBaseURL(ts.URL), WithCredentialsProvider(test.creds))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		requests = 0
+		_, err = c.CreatePayment("DERO", 1)
+		if (err != nil) != test.expectError {
+			t.Errorf("Expected error: %t. Got: %v\n", test.expectError, err)
+		}
+		if requests != test.expectedRequests {
+			t.Errorf("Expected %d requests. Got: %d\n", test.expectedRequests, requests)
+		}
+	}
+}
+
+func TestCredentialsRotationBodyNotReplayable(t *testing.T) {
+	requests := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		err := sendErrorResponse(w, http.StatusForbidden, "Forbidden")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	creds := StaticCredentials{{invalidAPIKey, mustParseSecretKey(t, invalidSecretKey)}, {validAPIKey, mustParseSecretKey(t, validSecretKey)}}
+	c, err := NewClient(nil, WithBaseURL(ts.URL), WithCredentialsProvider(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := c.NewRequest(http.MethodPost, "/payment", nil, map[string]interface{}{"currency": "DERO", "amount": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.GetBody = nil
+
+	err = c.SendSignedRequest(req, nil)
+	if !errors.Is(err, ErrForbidden) || !strings.Contains(err.Error(), "body can not be replayed") {
+		t.Errorf("Expected error explaining the request was not sent again. Got: %v\n", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 request. Got: %d\n", requests)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deromerchant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials.json")
+	err = ioutil.WriteFile(path, []byte(`[{"apiKey":"`+validAPIKey+`","secretKey":"`+validSecretKey+`"}]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFileCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := f.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 1 || creds[0].APIKey != validAPIKey {
+		t.Errorf("Expected credentials with API key %s. Got: %+v\n", validAPIKey, creds)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`[{"apiKey":"`+invalidAPIKey+`","secretKey":"`+invalidSecretKey+`"},{"apiKey":"`+validAPIKey+`","secretKey":"`+validSecretKey+`"}]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	err = os.Chtimes(path, future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err = f.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 || creds[0].APIKey != invalidAPIKey || !creds[1].SecretKey.Equal(mustParseSecretKey(t, validSecretKey)) {
+		t.Errorf("Expected credentials to be reloaded. Got: %+v\n", creds)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`[{"apiKey":"`+validAPIKey+`","secretKey":"not hex"}]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future = future.Add(time.Minute)
+	err = os.Chtimes(path, future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// File being rewritten: last good credentials are kept
+	creds, err = f.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 || creds[0].APIKey != invalidAPIKey {
+		t.Errorf("Expected last good credentials to be kept. Got: %+v\n", creds)
+	}
+
+	_, err = NewFileCredentials(path)
+	if !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSecretKey, err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	os.Setenv(EnvAPIKey, validAPIKey)
+	os.Setenv(EnvSecretKey, validSecretKey)
+	defer os.Unsetenv(EnvAPIKey)
+	defer os.Unsetenv(EnvSecretKey)
+
+	var e EnvCredentials
+
+	creds, err := e.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 1 || creds[0].APIKey != validAPIKey {
+		t.Errorf("Expected credentials with API key %s. Got: %+v\n", validAPIKey, creds)
+	}
+
+	os.Setenv(EnvNextAPIKey, invalidAPIKey)
+	os.Setenv(EnvNextSecretKey, invalidSecretKey)
+	defer os.Unsetenv(EnvNextAPIKey)
+	defer os.Unsetenv(EnvNextSecretKey)
+
+	creds, err = e.Credentials(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 || creds[1].APIKey != invalidAPIKey {
+		t.Errorf("Expected next credentials with API key %s. Got: %+v\n", invalidAPIKey, creds)
+	}
+
+	os.Unsetenv(EnvAPIKey)
+	os.Unsetenv(EnvNextAPIKey)
+	_, err = e.Credentials(context.Background())
+	if err != ErrNoCredentials {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrNoCredentials, err)
+	}
+}
@@ -0,0 +1,239 @@
+package deromerchant
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DecodeMode is the way responses of the API are decoded.
+type DecodeMode int
+
+// Modes of DecodeOptions.
+const (
+	DecodeDefault DecodeMode = iota // Like encoding/json: unknown fields are dropped and wrong types fail the request.
+	DecodeStrict                    // Unknown fields and wrong types fail the request with an error matching ErrDecode.
+	DecodeLenient                   // Unknown fields are kept in the Extra field of Payment and PaymentUpdateEvent, and fields of the wrong type are left zero.
+)
+
+// DriftKind is the kind of a SchemaDrift.
+type DriftKind int
+
+// Kinds of SchemaDrift.
+const (
+	DriftUnknownField DriftKind = iota // Field sent by the server unknown to the SDK.
+	DriftTypeMismatch                  // Field sent by the server with a type different from the one expected by the SDK.
+)
+
+func (k DriftKind) String() string {
+	switch k {
+	case DriftUnknownField:
+		return "unknown field"
+	case DriftTypeMismatch:
+		return "type mismatch"
+	default:
+		return fmt.Sprintf("DriftKind(%d)", int(k))
+	}
+}
+
+// SchemaDrift is a difference between a response of the API and the structs of the SDK, a sign the API changed.
+// Type is the name of the struct, Path the path of the field in the response (e.g. "payments[0].newField") and Value its raw JSON value.
+type SchemaDrift struct {
+	Kind  DriftKind
+	Type  string
+	Path  string
+	Value json.RawMessage
+}
+
+func (d SchemaDrift) String() string {
+	return fmt.Sprintf("%s %s in %s: %s", d.Kind, d.Path, d.Type, d.Value)
+}
+
+// SchemaDriftError is the error returned in DecodeStrict mode when a response drifts from the structs of the SDK.
+// Client methods wrap it in an error matching ErrDecode.
+type SchemaDriftError struct {
+	Drifts []SchemaDrift
+}
+
+func (e *SchemaDriftError) Error() string {
+	s := make([]string, len(e.Drifts))
+	for i, d := range e.Drifts {
+		s[i] = d.String()
+	}
+	return "DeroMerchant: schema drift: " + strings.Join(s, "; ")
+}
+
+// DecodeOptions is a struct that holds the options used to decode responses of the API and webhook requests.
+// OnDrift, if provided, is called for every SchemaDrift found, in any Mode.
+type DecodeOptions struct {
+	Mode    DecodeMode
+	OnDrift func(d SchemaDrift)
+}
+
+// WithDecodeOptions sets how the Client decodes the responses of the API.
+func WithDecodeOptions(o DecodeOptions) Option {
+	return func(c *Client) error {
+		switch o.Mode {
+		case DecodeDefault, DecodeStrict, DecodeLenient:
+		default:
+			return fmt.Errorf("DeroMerchant Client: unknown decode mode %d", o.Mode)
+		}
+
+		c.decoding = &o
+		return nil
+	}
+}
+
+// decodeJSON decodes b into v according to o. o may be nil.
+func decodeJSON(b []byte, v interface{}, o *DecodeOptions) error {
+	if o == nil || (o.Mode == DecodeDefault && o.OnDrift == nil) {
+		return json.Unmarshal(b, v)
+	}
+
+	err := json.Unmarshal(b, v)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return err // Malformed JSON
+	}
+
+	var drifts []SchemaDrift
+	walkDrift(b, reflect.ValueOf(v), "", o.Mode == DecodeLenient, func(d SchemaDrift) {
+		drifts = append(drifts, d)
+		if o.OnDrift != nil {
+			o.OnDrift(d)
+		}
+	})
+
+	switch {
+	case o.Mode == DecodeStrict && len(drifts) > 0:
+		return &SchemaDriftError{Drifts: drifts}
+	case o.Mode == DecodeLenient:
+		return nil
+	default:
+		return err
+	}
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	extraType = reflect.TypeOf(map[string]json.RawMessage(nil))
+)
+
+// walkDrift compares the JSON b with v, the value it was decoded into, and reports the differences.
+// If keepExtra is true, unknown fields are stored in the Extra field of structs that have one.
+func walkDrift(b []byte, v reflect.Value, path string, keepExtra bool, report func(d SchemaDrift)) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(b, &obj) != nil {
+			return
+		}
+		walkStruct(obj, v, path, keepExtra, report)
+	case reflect.Slice, reflect.Array:
+		var arr []json.RawMessage
+		if json.Unmarshal(b, &arr) != nil {
+			return
+		}
+		for i := 0; i < len(arr) && i < v.Len(); i++ {
+			walkDrift(arr[i], v.Index(i), fmt.Sprintf("%s[%d]", path, i), keepExtra, report)
+		}
+	}
+}
+
+func walkStruct(obj map[string]json.RawMessage, v reflect.Value, path string, keepExtra bool, report func(d SchemaDrift)) {
+	t := v.Type()
+
+	fields := make(map[string]int)
+	extra := -1
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if f.Name == "Extra" && f.Type == extraType {
+			extra = i
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = i // encoding/json matches keys case-insensitively
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		raw := obj[k]
+		fieldPath := k
+		if path != "" {
+			fieldPath = path + "." + k
+		}
+
+		i, ok := fields[strings.ToLower(k)]
+		if !ok {
+			report(SchemaDrift{Kind: DriftUnknownField, Type: t.Name(), Path: fieldPath, Value: raw})
+
+			if keepExtra && extra >= 0 {
+				ev := v.Field(extra)
+				if ev.IsNil() {
+					ev.Set(reflect.MakeMap(extraType))
+				}
+				ev.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(append(json.RawMessage(nil), raw...)))
+			}
+			continue
+		}
+
+		fv := v.Field(i)
+		if !typeMatches(raw, fv.Type()) {
+			report(SchemaDrift{Kind: DriftTypeMismatch, Type: t.Name(), Path: fieldPath, Value: raw})
+			continue
+		}
+		walkDrift(raw, fv, fieldPath, keepExtra, report)
+	}
+}
+
+// typeMatches returns whether the JSON value raw can be decoded into a value of type t.
+func typeMatches(raw json.RawMessage, t reflect.Type) bool {
+	raw = bytes.TrimSpace(raw)
+	if bytes.Equal(raw, []byte("null")) {
+		return true
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && t != timeType, t.Kind() == reflect.Map:
+		return len(raw) > 0 && raw[0] == '{'
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8, t.Kind() == reflect.Array:
+		return len(raw) > 0 && raw[0] == '['
+	default:
+		return json.Unmarshal(raw, reflect.New(t).Interface()) == nil
+	}
+}
@@ -0,0 +1,165 @@
+package deromerchant
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	const body = `{"payments": [{"paymentID": "a", "status": "paid", "ttl": "10", "refunded": true}], "totalPages": 1, "cursor": "x"}`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		mode           DecodeMode
+		expectError    bool
+		expectedExtra  string
+		expectedDrifts []SchemaDrift
+	}{
+		{mode: DecodeDefault, expectError: true},
+		{mode: DecodeStrict, expectError: true},
+		{mode: DecodeLenient, expectError: false, expectedExtra: "true"},
+	}
+
+	expectedDrifts := []SchemaDrift{
+		{Kind: DriftUnknownField, Type: "GetFilteredPaymentsResponse", Path: "cursor"},
+		{Kind: DriftUnknownField, Type: "Payment", Path: "payments[0].refunded"},
+		{Kind: DriftTypeMismatch, Type: "Payment", Path: "payments[0].ttl"},
+	}
+
+	for _, test := range tests {
+		var drifts []SchemaDrift
+		c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithBaseURL(ts.URL), WithDecodeOptions(DecodeOptions{
+			Mode: test.mode,
+			OnDrift: func(d SchemaDrift) {
+				drifts = append(drifts, d)
+			},
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := c.GetFilteredPayments(10, 1, "", "", "", "")
+		if err == nil {
+			if test.expectError {
+				t.Errorf("Mode %d: expected error\n", test.mode)
+			}
+
+			p := resp.Payments[0]
+			if p.PaymentID != "a" || p.TTL != 0 || string(p.Extra["refunded"]) != test.expectedExtra {
+				t.Errorf("Mode %d: unexpected payment: %+v\n", test.mode, p)
+			}
+		} else {
+			if !test.expectError {
+				t.Errorf("Mode %d: error not expected. Got: %v\n", test.mode, err)
+			}
+			if !errors.Is(err, ErrDecode) {
+				t.Errorf("Mode %d: expected decode error. Got: %v\n", test.mode, err)
+			}
+
+			var driftErr *SchemaDriftError
+			if errors.As(err, &driftErr) != (test.mode == DecodeStrict) {
+				t.Errorf("Mode %d: unexpected schema drift error: %v\n", test.mode, err)
+			}
+		}
+
+		if len(drifts) != len(expectedDrifts) {
+			t.Errorf("Mode %d: expected %d drifts. Got: %v\n", test.mode, len(expectedDrifts), drifts)
+			continue
+		}
+		for i, d := range drifts {
+			if d.Kind != expectedDrifts[i].Kind || d.Type != expectedDrifts[i].Type || d.Path != expectedDrifts[i].Path {
+				t.Errorf("Mode %d: expected drift: %v. Got: %v\n", test.mode, expectedDrifts[i], d)
+			}
+		}
+	}
+
+	// Known fields in any case are not drifts
+	var p *Payment
+	err := decodeJSON([]byte(`{"PaymentID": "a", "creationTime": "2020-01-01T00:00:00Z"}`), &p, &DecodeOptions{Mode: DecodeStrict})
+	if err != nil {
+		t.Errorf("Error not expected. Got: %v\n", err)
+	}
+}
+
+func TestParseWebhookRequestWithOptions(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewBufferString(`{"paymentID": "a", "status": "paid", "txid": "b"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := ParseWebhookRequestWithOptions(req, &DecodeOptions{Mode: DecodeLenient})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.PaymentID != "a" || string(e.Extra["txid"]) != `"b"` {
+		t.Errorf("Unexpected event: %+v\n", e)
+	}
+
+	_, err = ParseWebhookRequestWithOptions(req, &DecodeOptions{Mode: DecodeStrict})
+	var driftErr *SchemaDriftError
+	if !errors.As(err, &driftErr) || len(driftErr.Drifts) != 1 {
+		t.Errorf("Expected schema drift error. Got: %v\n", err)
+	}
+
+	// Default mode drops unknown fields
+	e, err = ParseWebhookRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Extra != nil {
+		t.Errorf("Extra not expected. Got: %v\n", e.Extra)
+	}
+	// Webhooks verified by webhook secret key, key set and store registry
+	const webhookSecretKey = "010f2b45384c57bd388bccb520722abd8d5a61f66ca71fcd25bf7942d067ca73"
+	lenient := &DecodeOptions{Mode: DecodeLenient}
+
+	newSignedRequest := func() *http.Request {
+		body := []byte(`{"paymentID": "a", "status": "paid", "txid": "b"}`)
+		s, err := mustParseSecretKey(t, webhookSecretKey).sign(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/webhook/a", bytes.NewReader(body))
+		req.Header.Set("X-Signature", hex.EncodeToString(s))
+		return req
+	}
+
+	_, e, err = VerifyAndParseWebhookRequestWithOptions(newSignedRequest(), webhookSecretKey, lenient)
+	if err != nil || string(e.Extra["txid"]) != `"b"` {
+		t.Errorf("Expected Extra from VerifyAndParseWebhookRequestWithOptions. Got: %+v, %v\n", e, err)
+	}
+
+	keys, err := NewWebhookKeySet(WebhookKey{ID: "a", Key: mustParseSecretKey(t, webhookSecretKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, e, err = keys.VerifyAndParseWithOptions(newSignedRequest(), lenient)
+	if err != nil || string(e.Extra["txid"]) != `"b"` {
+		t.Errorf("Expected Extra from WebhookKeySet.VerifyAndParseWithOptions. Got: %+v, %v\n", e, err)
+	}
+
+	r := NewStoreRegistry(WithDecodeOptions(*lenient))
+	_, err = r.Add(StoreConfig{ID: "a", APIKey: apiKey, SecretKey: secretKey, WebhookSecretKey: webhookSecretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, storeID := range []string{"a", ""} {
+		var handled *PaymentUpdateEvent
+		h := r.WebhookHandler(func(req *http.Request) string { return storeID }, func(w http.ResponseWriter, req *http.Request, s *Store, e *PaymentUpdateEvent) {
+			handled = e
+		})
+		h.ServeHTTP(httptest.NewRecorder(), newSignedRequest())
+		if handled == nil || string(handled.Extra["txid"]) != `"b"` {
+			t.Errorf("Expected Extra from StoreRegistry.WebhookHandler with store ID %q. Got: %+v\n", storeID, handled)
+		}
+	}
+}
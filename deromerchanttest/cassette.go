@@ -0,0 +1,340 @@
+package deromerchanttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+// RecorderMode is the mode of a Recorder.
+type RecorderMode int
+
+// Modes of Recorder.
+const (
+	ModeReplay RecorderMode = iota // Requests are answered with the interactions of the cassette. Nothing is sent.
+	ModeRecord                     // Requests are sent and interactions are recorded, to be written to the cassette by Save.
+)
+
+// ErrNoInteraction is returned by Recorder in ModeReplay when no interaction of the cassette matches a request.
+var ErrNoInteraction = errors.New("deromerchanttest: no matching interaction in cassette")
+
+// Redacted replaces the values of redacted headers in cassettes.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers redacted by Recorder if RecorderOptions.RedactHeaders is nil.
+var DefaultRedactedHeaders = []string{
+	"X-API-Key",
+	"X-Signature",
+	deromerchant.SignatureV2Header,
+	deromerchant.SignatureTimestampHeader,
+	deromerchant.SignatureNonceHeader,
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// Cassette is the content of a cassette file: the interactions with the API, in the order they were recorded.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request sent to the API and its response.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request.
+type CassetteRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded response.
+type CassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// RecorderOptions is a struct that holds the options of a Recorder.
+// Transport sends the requests in ModeRecord. If not provided, http.DefaultTransport is used.
+// RedactHeaders are replaced by Redacted in recorded requests and responses. If nil, DefaultRedactedHeaders are redacted.
+// RedactBody, if provided, is applied to recorded request and response bodies (e.g. to remove personal data).
+// SecretKey, if provided, is used in ModeReplay to verify the signature of requests (SignatureV1 for POST /payment, SignatureV2 if sent).
+// Clock is the clock SignatureV2 timestamps are checked against, e.g. the FakeClock passed to deromerchant.WithClock. If not provided, the system clock is used.
+type RecorderOptions struct {
+	Mode          RecorderMode
+	Transport     http.RoundTripper
+	RedactHeaders []string
+	RedactBody    func(body []byte) []byte
+	SecretKey     *deromerchant.SecretKey
+	Clock         deromerchant.Clock
+}
+
+// Recorder is an http.RoundTripper that records interactions with the API to a cassette file and replays them.
+// In ModeReplay, a request matches an interaction with the same method, URL (query parameters in any order) and body.
+// Signature headers change with keys, timestamps and nonces so they are not compared, but a request must be signed if the recorded one was.
+// Each interaction is replayed once, in the order recorded.
+// Use NewRecorder to create a new Recorder, and pass it to deromerchant.WithTransport.
+type Recorder struct {
+	path string
+	o    RecorderOptions
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder returns a new Recorder of the cassette file at path. o is optional.
+// In ModeReplay, the cassette file must exist.
+func NewRecorder(path string, o *RecorderOptions) (*Recorder, error) {
+	r := &Recorder{path: path}
+	if o != nil {
+		r.o = *o
+	}
+
+	if r.o.Transport == nil {
+		r.o.Transport = http.DefaultTransport
+	}
+	if r.o.RedactHeaders == nil {
+		r.o.RedactHeaders = DefaultRedactedHeaders
+	}
+	if r.o.Clock == nil {
+		r.o.Clock = systemClock{}
+	}
+
+	switch r.o.Mode {
+	case ModeRecord:
+	case ModeReplay:
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(b, &r.cassette)
+		if err != nil {
+			return nil, fmt.Errorf("deromerchanttest: invalid cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("deromerchanttest: unknown recorder mode %d", r.o.Mode)
+	}
+
+	return r, nil
+}
+
+// RoundTrip records or replays req, depending on the mode of the Recorder. req is not modified.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests are sent and verified as a clone with a body readable again, as RoundTrip must not modify req
+	clone := req.Clone(req.Context())
+	if body != nil {
+		clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		clone.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	var resp *http.Response
+	if r.o.Mode == ModeRecord {
+		resp, err = r.record(clone, body)
+	} else {
+		resp, err = r.replay(clone, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Request = req
+	return resp, nil
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.o.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := &Interaction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redactHeader(req.Header),
+			Body:   string(r.redactBody(body)),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       string(r.redactBody(respBody)),
+		},
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n, i := range r.cassette.Interactions {
+		if r.used[n] || !r.matches(i, req, body) {
+			continue
+		}
+
+		err := r.verifySignature(req, body)
+		if err != nil {
+			return nil, err
+		}
+
+		r.used[n] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        i.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewBufferString(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+// matches must be called with r.mu held.
+func (r *Recorder) matches(i *Interaction, req *http.Request, body []byte) bool {
+	if i.Request.Method != req.Method {
+		return false
+	}
+
+	u, err := url.Parse(i.Request.URL)
+	if err != nil || u.Scheme != req.URL.Scheme || u.Host != req.URL.Host || u.EscapedPath() != req.URL.EscapedPath() {
+		return false
+	}
+	if u.Query().Encode() != req.URL.Query().Encode() {
+		return false
+	}
+
+	if i.Request.Body != string(r.redactBody(body)) {
+		return false
+	}
+
+	for _, h := range []string{"X-Signature", deromerchant.SignatureVersionHeader} {
+		if (i.Request.Header.Get(h) == "") != (req.Header.Get(h) == "") {
+			return false // Signed requests only match signed requests
+		}
+	}
+
+	return true
+}
+
+// verifySignature checks the signature of req with the Secret Key of the options, if any.
+func (r *Recorder) verifySignature(req *http.Request, body []byte) error {
+	if r.o.SecretKey == nil {
+		return nil
+	}
+
+	if req.Header.Get("X-Signature") != "" {
+		err := deromerchant.VerifyRequestSignatureV1(req, r.o.SecretKey)
+		if err != nil {
+			return err
+		}
+	}
+	if req.Header.Get(deromerchant.SignatureVersionHeader) != "" {
+		return deromerchant.VerifyRequestSignatureAt(req, r.o.SecretKey, 0, r.o.Clock.Now())
+	}
+	return nil
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, k := range r.o.RedactHeaders {
+		if h.Get(k) != "" {
+			h.Set(k, Redacted)
+		}
+	}
+	return h
+}
+
+func (r *Recorder) redactBody(body []byte) []byte {
+	if r.o.RedactBody == nil {
+		return body
+	}
+	return r.o.RedactBody(body)
+}
+
+// Unused returns the interactions of the cassette not replayed yet. It returns nil in ModeRecord.
+func (r *Recorder) Unused() []*Interaction {
+	if r.o.Mode != ModeReplay {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []*Interaction
+	for n, i := range r.cassette.Interactions {
+		if !r.used[n] {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// Save writes the interactions recorded to the cassette file. It does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.o.Mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(&r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.path, b, 0644)
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
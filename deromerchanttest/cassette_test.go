@@ -0,0 +1,279 @@
+package deromerchanttest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payments.json")
+
+	s, ts := newTestServer(t, &ServerOptions{})
+	defer s.Close()
+
+	// Record
+	rec, err := NewRecorder(path, &RecorderOptions{Mode: ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := deromerchant.NewClient(&deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey},
+		deromerchant.WithBaseURL(ts.URL+APIPrefix), deromerchant.WithTransport(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := c.CreatePayment("DERO", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetFilteredPayments(10, 1, "creation_time", "desc", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = rec.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Close() // Replay must not reach the server
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), apiKey) || !strings.Contains(string(b), Redacted) {
+		t.Errorf("Expected API key to be redacted from cassette:\n%s\n", b)
+	}
+
+	// Replay, with another key signing requests
+	tests := []struct {
+		secretKey   string
+		verifyKey   string
+		expectError bool
+	}{
+		{secretKey: secretKey, verifyKey: secretKey, expectError: false},
+		{secretKey: webhookSecretKey, verifyKey: "", expectError: false},
+		{secretKey: webhookSecretKey, verifyKey: secretKey, expectError: true},
+	}
+
+	for _, test := range tests {
+		o := &RecorderOptions{Mode: ModeReplay}
+		if test.verifyKey != "" {
+			o.SecretKey, err = deromerchant.ParseSecretKey(test.verifyKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		rec, err := NewRecorder(path, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := deromerchant.NewClient(&deromerchant.ClientOptions{APIKey: apiKey, SecretKey: test.secretKey},
+			deromerchant.WithBaseURL(ts.URL+APIPrefix), deromerchant.WithTransport(rec))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := c.CreatePayment("DERO", 1)
+		if err != nil {
+			if !test.expectError {
+				t.Errorf("Error not expected. Got: %v\n", err)
+			}
+			continue
+		}
+		if test.expectError {
+			t.Error("Expected error")
+			continue
+		}
+		if p.PaymentID != created.PaymentID {
+			t.Errorf("Expected replayed Payment ID: %s. Got: %s\n", created.PaymentID, p.PaymentID)
+		}
+
+		// Query parameters in any order match
+		req, err := c.NewRequest(http.MethodGet, "/payments", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.URL.RawQuery = "status=&sort_by=creation_time&page=1&order_by=desc&limit=10&currency="
+
+		var resp deromerchant.GetFilteredPaymentsResponse
+		err = c.SendRequest(req, &resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Payments) != 1 {
+			t.Errorf("Expected 1 payment. Got: %d\n", len(resp.Payments))
+		}
+
+		// Interactions are replayed once
+		_, err = c.Ping()
+		if !errors.Is(err, ErrNoInteraction) {
+			t.Errorf("Expected error: %v. Got: %v\n", ErrNoInteraction, err)
+		}
+		if n := len(rec.Unused()); n != 0 {
+			t.Errorf("Expected every interaction to be replayed. Got %d unused\n", n)
+		}
+	}
+
+	// Unsigned request does not match signed one
+	rec, err = NewRecorder(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+APIPrefix+"/payment", strings.NewReader(`{"currency":"DERO","amount":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", apiKey)
+
+	_, err = (&http.Client{Transport: rec}).Do(req)
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrNoInteraction, err)
+	}
+
+	_, err = NewRecorder(filepath.Join(dir, "missing.json"), nil)
+	if err == nil {
+		t.Error("Expected error for missing cassette")
+	}
+}
+
+func TestRecorderSignatureV2Clock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ping.json")
+
+	s, ts := newTestServer(t, &ServerOptions{})
+	defer s.Close()
+	defer ts.Close()
+
+	rec, err := NewRecorder(path, &RecorderOptions{Mode: ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey, SignatureVersion: deromerchant.SignatureV2}
+	c, err := deromerchant.NewClient(o, deromerchant.WithBaseURL(ts.URL+APIPrefix), deromerchant.WithTransport(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rec.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := deromerchant.ParseSecretKey(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay with a client signing at a fake time, far from the system clock
+	clk := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		clock       deromerchant.Clock
+		expectError bool
+	}{
+		{clock: clk, expectError: false},
+		{clock: nil, expectError: true},
+	}
+
+	for _, test := range tests {
+		rec, err := NewRecorder(path, &RecorderOptions{Mode: ModeReplay, SecretKey: key, Clock: test.clock})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := deromerchant.NewClient(o, deromerchant.WithBaseURL(ts.URL+APIPrefix), deromerchant.WithTransport(rec), deromerchant.WithClock(clk))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Ping()
+		if (err != nil) != test.expectError {
+			t.Errorf("Expected error: %v. Got: %v\n", test.expectError, err)
+		}
+	}
+}
+
+func TestRecorderDoesNotModifyRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payment.json")
+
+	var received string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+		w.Write([]byte(`{"paymentID":"a"}`))
+	}))
+	defer ts.Close()
+
+	const payload = `{"currency":"DERO","amount":1}`
+	for _, mode := range []RecorderMode{ModeRecord, ModeReplay} {
+		rec, err := NewRecorder(path, &RecorderOptions{Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/payment", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-API-Key", apiKey)
+		body := req.Body
+
+		resp, err := rec.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if req.Body != body {
+			t.Errorf("Expected request body not to be replaced (mode %d)\n", mode)
+		}
+		if len(req.Header) != 1 || req.Header.Get("X-API-Key") != apiKey {
+			t.Errorf("Expected request header not to be modified (mode %d). Got: %v\n", mode, req.Header)
+		}
+		if resp.Request != req {
+			t.Errorf("Expected response of the request sent (mode %d)\n", mode)
+		}
+
+		if mode == ModeRecord {
+			if received != payload {
+				t.Errorf("Expected body sent: %s. Got: %s\n", payload, received)
+			}
+			err = rec.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package deromerchanttest provides utilities for testing code that uses the DERO Merchant Go SDK.
+package deromerchanttest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a deromerchant.Clock whose time only moves when Advance or Set is called.
+// Use NewFakeClock to create a new FakeClock. FakeClock is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+// NewFakeClock returns a new FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the FakeClock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the current time once the FakeClock has been advanced by d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{
+		until: c.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		w.c <- c.now
+		return w.c
+	}
+
+	c.waiters = append(c.waiters, w)
+	return w.c
+}
+
+// Advance moves the FakeClock forward by d, firing the channels returned by After that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set sets the FakeClock to t, firing the channels returned by After that are due.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(t)
+}
+
+// set must be called with c.mu held.
+func (c *FakeClock) set(t time.Time) {
+	c.now = t
+
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.until.After(t) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- t
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of channels returned by After that have not fired yet.
+// Tests can poll it to know when a goroutine is blocked waiting on the FakeClock before calling Advance.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntilWaiters waits until at least n channels returned by After have not fired yet, or timeout elapses (in real time).
+// It returns whether there are n waiters.
+func (c *FakeClock) BlockUntilWaiters(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for c.Waiters() < n {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
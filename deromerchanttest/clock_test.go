@@ -0,0 +1,148 @@
+package deromerchanttest
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+const apiKey = "bfe737bcdc5d8886a03be6e6c34c545d85ab8fa39052b9e3be36d3626c180a6f"
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+	clk := NewFakeClock(epoch)
+
+	c := clk.After(time.Minute)
+	now := clk.After(0)
+
+	select {
+	case <-now:
+	default:
+		t.Error("Expected channel of After(0) to fire immediately")
+	}
+
+	clk.Advance(59 * time.Second)
+	select {
+	case <-c:
+		t.Error("Channel not expected to fire before a minute")
+	default:
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case fired := <-c:
+		if !fired.Equal(epoch.Add(time.Minute)) {
+			t.Errorf("Expected channel to fire at %v. Got: %v\n", epoch.Add(time.Minute), fired)
+		}
+	default:
+		t.Error("Expected channel to fire after a minute")
+	}
+
+	if clk.Waiters() != 0 {
+		t.Errorf("Expected no waiters. Got: %d\n", clk.Waiters())
+	}
+}
+
+func TestFakeClockRateLimiter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	clk := NewFakeClock(epoch)
+	c, err := deromerchant.NewClient(&deromerchant.ClientOptions{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		RateLimit: &deromerchant.RateLimitOptions{RequestsPerSecond: 1, Burst: 1},
+	}, deromerchant.WithBaseURL(ts.URL), deromerchant.WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.PingWithContext(context.Background())
+		done <- err
+	}()
+
+	if !clk.BlockUntilWaiters(1, time.Second) {
+		t.Fatal("Expected second request to wait for the rate limiter")
+	}
+	clk.Advance(time.Second)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected second request to be sent once the fake clock advanced")
+	}
+}
+
+func TestFakeClockCircuitBreaker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	clk := NewFakeClock(epoch)
+	c, err := deromerchant.NewClient(&deromerchant.ClientOptions{
+		APIKey:         apiKey,
+		SecretKey:      secretKey,
+		CircuitBreaker: &deromerchant.CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Minute},
+	}, deromerchant.WithBaseURL(ts.URL), deromerchant.WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Ping()
+	if s := c.CircuitBreakerStats(); s.State != deromerchant.CircuitOpen || !s.OpenedAt.Equal(epoch) {
+		t.Errorf("Expected circuit opened at %v. Got: %+v\n", epoch, s)
+	}
+
+	clk.Advance(time.Minute)
+	if s := c.CircuitBreakerStats(); s.State != deromerchant.CircuitHalfOpen {
+		t.Errorf("Expected half-open circuit after cooldown. Got: %v\n", s.State)
+	}
+}
+
+func TestRandomNonce(t *testing.T) {
+	var nonces []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.Header.Get(deromerchant.SignatureNonceHeader))
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		c, err := deromerchant.NewClient(&deromerchant.ClientOptions{
+			APIKey:           apiKey,
+			SecretKey:        "b3cef2080cf82a010acba9bd00c9bd5797ec07767fbd7c08702a921d67c8155a",
+			SignatureVersion: deromerchant.SignatureV2,
+		}, deromerchant.WithBaseURL(ts.URL), deromerchant.WithRandom(rand.New(rand.NewSource(1))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Ping()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(nonces) != 2 || nonces[0] == "" || nonces[0] != nonces[1] {
+		t.Errorf("Expected same nonce from same random source. Got: %v\n", nonces)
+	}
+}
@@ -0,0 +1,188 @@
+package deromerchanttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+// Actions of a Scenario.
+const (
+	ActionPay    = "pay"    // Payments created become paid After the given delay.
+	ActionExpire = "expire" // Payments created become expired After the given delay.
+	ActionError  = "error"  // Payments created get status error After the given delay.
+	ActionFail   = "fail"   // Requests are answered with StatusCode instead of being handled.
+)
+
+// Scenario scripts the behavior of a Server.
+//
+// Scenarios with actions pay, expire and error apply to the Payments created with Currency (any currency if empty).
+// Scenarios with action fail apply to the requests with Method (any method if empty) whose path, relative to /api/v1,
+// starts with Path (any path if empty). StatusCode defaults to 500.
+// A Scenario applies Times times. If Times is 0, it applies forever. The first Scenario that applies wins.
+type Scenario struct {
+	Name       string   `json:"name,omitempty"`
+	Action     string   `json:"action"`
+	After      Duration `json:"after,omitempty"`
+	Currency   string   `json:"currency,omitempty"`
+	Method     string   `json:"method,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	StatusCode int      `json:"statusCode,omitempty"`
+	Times      int      `json:"times,omitempty"`
+}
+
+func (s *Scenario) validate() error {
+	switch s.Action {
+	case ActionPay, ActionExpire, ActionError, ActionFail:
+	default:
+		return fmt.Errorf("deromerchanttest: scenario %q: unknown action %q", s.Name, s.Action)
+	}
+
+	if s.After < 0 || s.Times < 0 {
+		return fmt.Errorf("deromerchanttest: scenario %q: negative after or times", s.Name)
+	}
+	if s.StatusCode != 0 && (s.StatusCode < 100 || s.StatusCode > 599) {
+		return fmt.Errorf("deromerchanttest: scenario %q: invalid status code %d", s.Name, s.StatusCode)
+	}
+
+	return nil
+}
+
+// matchesPayment must be called with the Server mutex held.
+func (s *Scenario) matchesPayment(p *deromerchant.Payment) bool {
+	if s.Action == ActionFail || s.Times < 0 {
+		return false
+	}
+	return s.Currency == "" || strings.EqualFold(s.Currency, p.Currency)
+}
+
+// matchesRequest must be called with the Server mutex held.
+func (s *Scenario) matchesRequest(r *http.Request, path string) bool {
+	if s.Action != ActionFail || s.Times < 0 {
+		return false
+	}
+	if s.Method != "" && !strings.EqualFold(s.Method, r.Method) {
+		return false
+	}
+	return strings.HasPrefix(path, s.Path)
+}
+
+// use counts one application of the Scenario. It must be called with the Server mutex held.
+func (s *Scenario) use() {
+	switch {
+	case s.Times == 1:
+		s.Times = -1 // Used up
+	case s.Times > 1:
+		s.Times--
+	}
+}
+
+// Duration is a time.Duration that is unmarshalled from JSON strings such as "5s" or "1m30s", or from numbers of seconds.
+type Duration time.Duration
+
+// MarshalJSON marshals d as a string such as "5s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON unmarshals a string such as "5s" or a number of seconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+
+	var seconds float64
+	err = json.Unmarshal(b, &seconds)
+	if err != nil {
+		return fmt.Errorf("deromerchanttest: invalid duration %s", b)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// scenarioFile is the format of the files read by LoadScenarios.
+type scenarioFile struct {
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// LoadScenarios reads a JSON object with a "scenarios" array from r. Example:
+//
+//	{"scenarios": [
+//		{"name": "pay after 5s", "action": "pay", "after": "5s"},
+//		{"name": "USD expire", "action": "expire", "currency": "USD"},
+//		{"name": "return 500 twice", "action": "fail", "path": "/payment/", "statusCode": 500, "times": 2}
+//	]}
+func LoadScenarios(r io.Reader) ([]Scenario, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var f scenarioFile
+	err := dec.Decode(&f)
+	if err != nil {
+		return nil, fmt.Errorf("deromerchanttest: invalid scenarios: %w", err)
+	}
+
+	for i := range f.Scenarios {
+		err := f.Scenarios[i].validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f.Scenarios, nil
+}
+
+// LoadScenariosYAML reads the YAML equivalent of the JSON read by LoadScenarios from r. Example:
+//
+//	scenarios:
+//	  - name: pay after 5s
+//	    action: pay
+//	    after: 5s
+//	  - name: return 500 twice
+//	    action: fail
+//	    path: /payment/
+//	    statusCode: 500
+//	    times: 2
+//
+// The SDK has no dependencies, so only the block mappings, block sequences, plain and quoted scalars and comments
+// needed by scenario files are supported. Flow collections (except []), anchors, tags and multi-line strings are not.
+func LoadScenariosYAML(r io.Reader) ([]Scenario, error) {
+	b, err := scenariosYAMLToJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadScenarios(bytes.NewReader(b))
+}
+
+// LoadScenarioFile reads the scenarios of the file at path: YAML (see LoadScenariosYAML) if its extension is .yaml or .yml,
+// JSON (see LoadScenarios) otherwise.
+func LoadScenarioFile(path string) ([]Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return LoadScenariosYAML(f)
+	}
+
+	return LoadScenarios(f)
+}
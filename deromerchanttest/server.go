@@ -0,0 +1,546 @@
+package deromerchanttest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+// APIPrefix is the path the Server serves the DERO Merchant API under.
+const APIPrefix = "/api/v1"
+
+// DefaultPaymentTTL is the time Payments created by a Server stay pending, unless a Scenario changes their status.
+const DefaultPaymentTTL = 60 * time.Minute
+
+// ServerOptions is a struct that holds the options of a Server.
+// APIKey and SecretKey are required. Requests are checked like on the DERO Merchant server: every request needs the X-API-Key header,
+// POST /payment needs a SignatureV1 X-Signature header and requests with SignatureV2 headers must have a valid signature.
+// If WebhookURL is set, webhooks signed with WebhookSecretKey are sent there every time the status of a Payment changes.
+// ExchangeRates are the amounts of each currency worth 1 DERO. Currencies not listed are worth 1 DERO.
+// Clock is optional. If not provided, the system clock is used. PaymentTTL defaults to DefaultPaymentTTL.
+// HTTPClient is used to send webhooks. If not provided, a client with a 10 seconds timeout is used.
+// Logf, if provided, is called for every request handled and webhook sent.
+type ServerOptions struct {
+	APIKey    string
+	SecretKey string
+
+	WebhookURL       string
+	WebhookSecretKey string
+
+	ExchangeRates map[string]float64
+	PaymentTTL    time.Duration
+	Scenarios     []Scenario
+
+	Clock      deromerchant.Clock
+	HTTPClient *http.Client
+	Logf       func(format string, args ...interface{})
+}
+
+// Server is a fake DERO Merchant server, to be used in tests or run locally (see cmd/deromerchant-mock).
+// It serves /ping, /payment and /payments under APIPrefix, keeping Payments in memory.
+// Use NewServer to create a new Server and Close to stop its scenarios.
+type Server struct {
+	o          ServerOptions
+	secretKey  *deromerchant.SecretKey
+	webhookKey []byte
+
+	mu        sync.Mutex
+	payments  map[string]*serverPayment
+	order     []string // Payment IDs in creation order
+	scenarios []Scenario
+
+	closeMu sync.Mutex // Separate from mu, as goroutines are started with or without mu held
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type serverPayment struct {
+	deromerchant.Payment
+	expiresAt time.Time
+}
+
+// NewServer returns a new Server.
+func NewServer(o *ServerOptions) (*Server, error) {
+	if o == nil {
+		o = &ServerOptions{}
+	}
+
+	s := &Server{
+		o:        *o,
+		payments: make(map[string]*serverPayment),
+		done:     make(chan struct{}),
+	}
+
+	if s.o.APIKey == "" {
+		return nil, errors.New("deromerchanttest: API key is required")
+	}
+
+	var err error
+	s.secretKey, err = deromerchant.ParseSecretKey(s.o.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.o.WebhookURL != "" {
+		_, err = deromerchant.ParseSecretKey(s.o.WebhookSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		s.webhookKey, _ = hex.DecodeString(s.o.WebhookSecretKey)
+	}
+
+	for i := range s.o.Scenarios {
+		err := s.o.Scenarios[i].validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.scenarios = append([]Scenario(nil), s.o.Scenarios...)
+
+	if s.o.PaymentTTL <= 0 {
+		s.o.PaymentTTL = DefaultPaymentTTL
+	}
+	if s.o.Clock == nil {
+		s.o.Clock = systemClock{}
+	}
+	if s.o.HTTPClient == nil {
+		s.o.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return s, nil
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Close stops the pending scenarios of the Server and waits for the webhooks being sent.
+// Webhooks of status changes after Close are not sent.
+func (s *Server) Close() {
+	s.closeMu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.closeMu.Unlock()
+
+	s.wg.Wait()
+}
+
+// spawn runs f in a goroutine waited for by Close, unless the Server is closed.
+func (s *Server) spawn(f func()) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		f()
+	}()
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.o.Logf != nil {
+		s.o.Logf(format, args...)
+	}
+}
+
+// Payment returns a copy of the Payment with paymentID, or nil if there is none.
+func (s *Server) Payment(paymentID string) *deromerchant.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[paymentID]
+	if !ok {
+		return nil
+	}
+	return s.snapshot(p)
+}
+
+// Payments returns a copy of every Payment, in creation order.
+func (s *Server) Payments() []*deromerchant.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := make([]*deromerchant.Payment, 0, len(s.order))
+	for _, id := range s.order {
+		ps = append(ps, s.snapshot(s.payments[id]))
+	}
+	return ps
+}
+
+// SetStatus sets the status of the Payment with paymentID and sends a webhook.
+func (s *Server) SetStatus(paymentID, status string) error {
+	s.mu.Lock()
+	p, ok := s.payments[paymentID]
+	if ok {
+		p.Status = status
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("deromerchanttest: no payment %s", paymentID)
+	}
+
+	s.sendWebhook(paymentID, status)
+	return nil
+}
+
+// snapshot returns a copy of p with the TTL left at the current time. It must be called with s.mu held.
+func (s *Server) snapshot(p *serverPayment) *deromerchant.Payment {
+	now := s.o.Clock.Now()
+	if p.Status == deromerchant.PaymentStatusPending && !now.Before(p.expiresAt) {
+		p.Status = deromerchant.PaymentStatusExpired
+		s.sendWebhook(p.PaymentID, p.Status)
+	}
+
+	cp := p.Payment
+	cp.TTL = 0
+	if cp.Status == deromerchant.PaymentStatusPending {
+		cp.TTL = int(math.Ceil(p.expiresAt.Sub(now).Minutes()))
+	}
+	return &cp
+}
+
+// ServeHTTP serves the DERO Merchant API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logf("%s %s", r.Method, r.URL.Path)
+
+	if !strings.HasPrefix(r.URL.Path, APIPrefix+"/") {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, APIPrefix)
+
+	if code, ok := s.failure(r, path); ok {
+		writeError(w, code, http.StatusText(code))
+		return
+	}
+
+	if r.Header.Get("X-API-Key") != s.o.APIKey {
+		writeError(w, deromerchant.ErrorCodeForbidden, "Forbidden")
+		return
+	}
+
+	if r.Method == http.MethodPost && path == "/payment" {
+		err := deromerchant.VerifyRequestSignatureV1(r, s.secretKey)
+		if err != nil {
+			writeError(w, deromerchant.ErrorCodeUnauthorized, "Unauthorized")
+			return
+		}
+	}
+	if r.Header.Get(deromerchant.SignatureVersionHeader) != "" {
+		err := deromerchant.VerifyRequestSignatureAt(r, s.secretKey, 0, s.o.Clock.Now())
+		if err != nil {
+			writeError(w, deromerchant.ErrorCodeUnauthorized, "Unauthorized")
+			return
+		}
+	}
+
+	switch {
+	case path == "/ping" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &deromerchant.PingResponse{Ping: "pong"})
+	case path == "/payment" && r.Method == http.MethodPost:
+		s.createPayment(w, r)
+	case strings.HasPrefix(path, "/payment/") && r.Method == http.MethodGet:
+		s.getPayment(w, strings.TrimPrefix(path, "/payment/"))
+	case path == "/payments" && r.Method == http.MethodPost:
+		s.getPayments(w, r)
+	case path == "/payments" && r.Method == http.MethodGet:
+		s.getFilteredPayments(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// failure returns the status code of the first fail Scenario that applies to r.
+func (s *Server) failure(r *http.Request, path string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.scenarios {
+		sc := &s.scenarios[i]
+		if !sc.matchesRequest(r, path) {
+			continue
+		}
+
+		sc.use()
+		if sc.StatusCode == 0 {
+			return http.StatusInternalServerError, true
+		}
+		return sc.StatusCode, true
+	}
+
+	return 0, false
+}
+
+func (s *Server) createPayment(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Currency string  `json:"currency"`
+		Amount   float64 `json:"amount"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Currency == "" || req.Amount <= 0 {
+		writeError(w, deromerchant.ErrorCodeBadRequest, "Bad Request")
+		return
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	rate := 1.0
+	if v, ok := s.o.ExchangeRates[currency]; ok && v > 0 {
+		rate = v
+	}
+	atomic := uint64(math.Round(req.Amount / rate * 1e12))
+
+	id, err := randomHex(32)
+	if err != nil {
+		writeError(w, deromerchant.ErrorCodeInternalServerError, "Internal Server Error")
+		return
+	}
+	addr, err := randomHex(48)
+	if err != nil {
+		writeError(w, deromerchant.ErrorCodeInternalServerError, "Internal Server Error")
+		return
+	}
+
+	now := s.o.Clock.Now()
+	p := &serverPayment{
+		Payment: deromerchant.Payment{
+			PaymentID:         id,
+			Status:            deromerchant.PaymentStatusPending,
+			Currency:          currency,
+			CurrencyAmount:    req.Amount,
+			ExchangeRate:      rate,
+			DeroAmount:        strconv.FormatFloat(float64(atomic)/1e12, 'f', 12, 64),
+			AtomicDeroAmount:  atomic,
+			IntegratedAddress: "dETi" + addr,
+			CreationTime:      now.UTC(),
+		},
+		expiresAt: now.Add(s.o.PaymentTTL),
+	}
+
+	s.mu.Lock()
+	s.payments[id] = p
+	s.order = append(s.order, id)
+	s.schedule(p)
+	resp := s.snapshot(p)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// schedule starts the first status Scenario that applies to p. It must be called with s.mu held.
+func (s *Server) schedule(p *serverPayment) {
+	for i := range s.scenarios {
+		sc := &s.scenarios[i]
+		if !sc.matchesPayment(&p.Payment) {
+			continue
+		}
+		sc.use()
+
+		status := map[string]string{
+			ActionPay:    deromerchant.PaymentStatusPaid,
+			ActionExpire: deromerchant.PaymentStatusExpired,
+			ActionError:  deromerchant.PaymentStatusError,
+		}[sc.Action]
+		after := s.o.Clock.After(time.Duration(sc.After))
+		id := p.PaymentID
+
+		s.spawn(func() {
+			select {
+			case <-s.done:
+				return
+			case <-after:
+			}
+
+			s.mu.Lock()
+			p := s.payments[id]
+			changed := p.Status == deromerchant.PaymentStatusPending
+			if changed {
+				p.Status = status
+			}
+			s.mu.Unlock()
+
+			if changed {
+				s.postWebhook(id, status)
+			}
+		})
+		return
+	}
+}
+
+func (s *Server) getPayment(w http.ResponseWriter, paymentID string) {
+	s.mu.Lock()
+	p, ok := s.payments[paymentID]
+	var resp *deromerchant.Payment
+	if ok {
+		resp = s.snapshot(p)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, deromerchant.ErrorCodeNotFound, "Payment Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getPayments(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	err := json.NewDecoder(r.Body).Decode(&ids)
+	if err != nil || len(ids) == 0 {
+		writeError(w, deromerchant.ErrorCodeBadRequest, "Bad Request")
+		return
+	}
+
+	resp := []*deromerchant.Payment{}
+	s.mu.Lock()
+	for _, id := range ids {
+		if p, ok := s.payments[id]; ok {
+			resp = append(resp, s.snapshot(p))
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getFilteredPayments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	page, _ := strconv.Atoi(q.Get("page"))
+	if limit < 1 {
+		limit = 10
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	var ps []*deromerchant.Payment
+	s.mu.Lock()
+	for _, id := range s.order {
+		p := s.snapshot(s.payments[id])
+		if q.Get("status") != "" && p.Status != q.Get("status") {
+			continue
+		}
+		if q.Get("currency") != "" && !strings.EqualFold(p.Currency, q.Get("currency")) {
+			continue
+		}
+		ps = append(ps, p)
+	}
+	s.mu.Unlock()
+
+	if q.Get("sort_by") == "currency_amount" {
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].CurrencyAmount < ps[j].CurrencyAmount })
+	}
+	if strings.EqualFold(q.Get("order_by"), "desc") {
+		for i, j := 0, len(ps)-1; i < j; i, j = i+1, j-1 {
+			ps[i], ps[j] = ps[j], ps[i]
+		}
+	}
+
+	resp := &deromerchant.GetFilteredPaymentsResponse{
+		Limit:         limit,
+		Page:          page,
+		TotalPayments: len(ps),
+		TotalPages:    (len(ps) + limit - 1) / limit,
+		Payments:      []*deromerchant.Payment{},
+	}
+	if start := (page - 1) * limit; start < len(ps) {
+		end := start + limit
+		if end > len(ps) {
+			end = len(ps)
+		}
+		resp.Payments = ps[start:end]
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// sendWebhook sends the webhook of a status change in the background.
+func (s *Server) sendWebhook(paymentID, status string) {
+	s.spawn(func() {
+		s.postWebhook(paymentID, status)
+	})
+}
+
+// postWebhook sends a webhook signed with the Webhook Secret Key to the webhook URL, if any.
+func (s *Server) postWebhook(paymentID, status string) {
+	if s.o.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(&deromerchant.PaymentUpdateEvent{PaymentID: paymentID, Status: status})
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.o.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		s.logf("webhook %s %s: %v", paymentID, status, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature", hex.EncodeToString(SignWebhook(body, s.webhookKey)))
+
+	resp, err := s.o.HTTPClient.Do(req)
+	if err != nil {
+		s.logf("webhook %s %s: %v", paymentID, status, err)
+		return
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	s.logf("webhook %s %s: %s", paymentID, status, resp.Status)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]*deromerchant.APIError{
+		"error": {Code: code, Message: message},
+	})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,332 @@
+package deromerchanttest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+const (
+	secretKey        = "b3cef2080cf82a010acba9bd00c9bd5797ec07767fbd7c08702a921d67c8155a"
+	webhookSecretKey = "010f2b45384c57bd388bccb520722abd8d5a61f66ca71fcd25bf7942d067ca73"
+)
+
+func newTestServer(t *testing.T, o *ServerOptions) (*Server, *httptest.Server) {
+	o.APIKey = apiKey
+	o.SecretKey = secretKey
+
+	s, err := NewServer(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, httptest.NewServer(s)
+}
+
+func newTestClient(t *testing.T, url string, o *deromerchant.ClientOptions) *deromerchant.Client {
+	c, err := deromerchant.NewClient(o, deromerchant.WithBaseURL(url+APIPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServer(t *testing.T) {
+	s, ts := newTestServer(t, &ServerOptions{
+		ExchangeRates: map[string]float64{"USD": 4},
+	})
+	defer ts.Close()
+	defer s.Close()
+
+	for _, version := range []int{deromerchant.SignatureV1, deromerchant.SignatureV2} {
+		c := newTestClient(t, ts.URL, &deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey, SignatureVersion: version})
+
+		_, err := c.Ping()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := c.CreatePayment("USD", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != deromerchant.PaymentStatusPending || p.AtomicDeroAmount != 2500000000000 || p.DeroAmount != "2.500000000000" || p.TTL != 60 {
+			t.Errorf("Unexpected payment: %+v\n", p)
+		}
+
+		got, err := c.GetPayment(p.PaymentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.PaymentID != p.PaymentID {
+			t.Errorf("Expected Payment ID: %s. Got: %s\n", p.PaymentID, got.PaymentID)
+		}
+
+		ps, err := c.GetPayments([]string{p.PaymentID, "unknown"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ps) != 1 {
+			t.Errorf("Expected 1 payment. Got: %d\n", len(ps))
+		}
+	}
+
+	c := newTestClient(t, ts.URL, &deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	resp, err := c.GetFilteredPayments(1, 2, "creation_time", "desc", deromerchant.PaymentStatusPending, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TotalPayments != 2 || resp.TotalPages != 2 || len(resp.Payments) != 1 || resp.Payments[0].PaymentID != s.Payments()[0].PaymentID {
+		t.Errorf("Unexpected filtered payments: %+v\n", resp)
+	}
+
+	_, err = c.GetPayment("unknown")
+	if !errors.Is(err, deromerchant.ErrNotFound) {
+		t.Errorf("Expected not found error. Got: %v\n", err)
+	}
+
+	// Wrong keys are rejected
+	tests := []struct {
+		apiKey      string
+		secretKey   string
+		expectedErr error
+	}{
+		{apiKey: strings.Repeat("0", 64), secretKey: secretKey, expectedErr: deromerchant.ErrForbidden},
+		{apiKey: apiKey, secretKey: webhookSecretKey, expectedErr: deromerchant.ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(t, ts.URL, &deromerchant.ClientOptions{APIKey: test.apiKey, SecretKey: test.secretKey})
+		_, err := c.CreatePayment("DERO", 1)
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("Expected error: %v. Got: %v\n", test.expectedErr, err)
+		}
+	}
+}
+
+func TestServerScenarios(t *testing.T) {
+	events := make(chan *deromerchant.PaymentUpdateEvent, 10)
+	webhooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, e, err := deromerchant.VerifyAndParseWebhookRequest(r, webhookSecretKey)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		events <- e
+	}))
+	defer webhooks.Close()
+
+	scenarios, err := LoadScenarios(strings.NewReader(`{"scenarios": [
+		{"name": "return 500 twice", "action": "fail", "method": "GET", "path": "/payment/", "times": 2},
+		{"name": "USD expire", "action": "expire", "currency": "USD"},
+		{"name": "pay after 5s", "action": "pay", "after": "5s"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := NewFakeClock(epoch)
+	s, ts := newTestServer(t, &ServerOptions{
+		WebhookURL:       webhooks.URL,
+		WebhookSecretKey: webhookSecretKey,
+		Scenarios:        scenarios,
+		Clock:            clk,
+	})
+	defer ts.Close()
+	defer s.Close()
+
+	c := newTestClient(t, ts.URL, &deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+
+	paid, err := c.CreatePayment("EUR", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := c.CreatePayment("USD", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire scenario applies immediately, pay scenario after 5 seconds
+	if !clk.BlockUntilWaiters(1, time.Second) {
+		t.Fatal("Expected pay scenario to wait for the clock")
+	}
+	clk.Advance(5 * time.Second)
+
+	statuses := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-events:
+			statuses[e.PaymentID] = e.Status
+		case <-time.After(time.Second):
+			t.Fatal("Expected webhook")
+		}
+	}
+	if statuses[paid.PaymentID] != deromerchant.PaymentStatusPaid || statuses[expired.PaymentID] != deromerchant.PaymentStatusExpired {
+		t.Errorf("Unexpected webhook statuses: %v\n", statuses)
+	}
+
+	// Fail scenario applies twice
+	for i := 0; i < 3; i++ {
+		p, err := c.GetPayment(paid.PaymentID)
+		if i < 2 {
+			if !errors.Is(err, deromerchant.ErrServer) {
+				t.Errorf("Expected server error. Got: %v\n", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != deromerchant.PaymentStatusPaid || p.TTL != 0 {
+			t.Errorf("Expected paid payment without TTL. Got: %+v\n", p)
+		}
+	}
+
+	// Payments expire after their TTL
+	pending, err := c.CreatePayment("DERO", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetStatus(pending.PaymentID, deromerchant.PaymentStatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-events
+
+	clk.Advance(DefaultPaymentTTL)
+	if p := s.Payment(pending.PaymentID); p.Status != deromerchant.PaymentStatusExpired {
+		t.Errorf("Expected expired payment. Got: %s\n", p.Status)
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	tests := []struct {
+		json        string
+		expectError bool
+	}{
+		{json: `{"scenarios": [{"action": "pay", "after": 1.5}]}`, expectError: false},
+		{json: `{"scenarios": [{"action": "refund"}]}`, expectError: true},
+		{json: `{"scenarios": [{"action": "pay", "after": "soon"}]}`, expectError: true},
+		{json: `{"scenarios": [{"action": "fail", "statusCode": 1000}]}`, expectError: true},
+		{json: `{"scenario": []}`, expectError: true},
+	}
+
+	for _, test := range tests {
+		scenarios, err := LoadScenarios(strings.NewReader(test.json))
+		if err == nil {
+			if test.expectError {
+				t.Errorf("Expected error for %s\n", test.json)
+			} else if time.Duration(scenarios[0].After) != 1500*time.Millisecond {
+				t.Errorf("Expected after: 1.5s. Got: %v\n", time.Duration(scenarios[0].After))
+			}
+		} else if !test.expectError {
+			t.Errorf("Error not expected. Got: %v\n", err)
+		}
+	}
+
+}
+
+func TestLoadScenariosYAML(t *testing.T) {
+	const scenariosYAML = `---
+# Scenarios of the QA environment
+scenarios:
+  - name: "return 500 twice" # Until retries are tested
+    action: fail
+    method: GET
+    path: /payment/
+    statusCode: 500
+    times: 2
+  -   name: 'USD # expire'
+      action: expire
+      currency: USD
+  - action: pay
+    after: 1.5
+`
+
+	scenarios, err := LoadScenariosYAML(strings.NewReader(scenariosYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Scenario{
+		{Name: "return 500 twice", Action: ActionFail, Method: "GET", Path: "/payment/", StatusCode: 500, Times: 2},
+		{Name: "USD # expire", Action: ActionExpire, Currency: "USD"},
+		{Action: ActionPay, After: Duration(1500 * time.Millisecond)},
+	}
+	if !reflect.DeepEqual(scenarios, expected) {
+		t.Errorf("Expected scenarios: %+v. Got: %+v\n", expected, scenarios)
+	}
+
+	tests := []string{
+		"scenarios:\n  - action: refund\n",
+		"scenarios:\n  - action: pay\n      after: 5s\n",
+		"scenarios:\n  - action: pay\n    action: pay\n",
+		"scenarios:\n  - {action: pay}\n",
+		"scenarios:\n  action: pay\n",
+		"scenario:\n  - action: pay\n",
+		"scenarios:\n\t- action: pay\n",
+	}
+
+	for _, test := range tests {
+		_, err := LoadScenariosYAML(strings.NewReader(test))
+		if err == nil {
+			t.Errorf("Expected error for %q\n", test)
+		}
+	}
+
+	// Files are read as YAML or JSON depending on their extension
+	dir, err := ioutil.TempDir("", "scenarios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scenarios.yml")
+	err = ioutil.WriteFile(path, []byte(scenariosYAML), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios, err = LoadScenarioFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 3 {
+		t.Errorf("Expected 3 scenarios. Got: %d\n", len(scenarios))
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	s, ts := newTestServer(t, &ServerOptions{})
+	defer ts.Close()
+
+	c := newTestClient(t, ts.URL, &deromerchant.ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	p, err := c.CreatePayment("DERO", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Status changes racing with Close must not start webhooks Close does not wait for
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.SetStatus(p.PaymentID, deromerchant.PaymentStatusPaid)
+		}()
+	}
+	s.Close()
+	wg.Wait()
+
+	s.Close() // Closing twice is a no-op
+}
@@ -0,0 +1,41 @@
+package deromerchanttest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	deromerchant "github.com/peppinux/dero-merchant-go-sdk"
+)
+
+// SignWebhook returns the signature of a webhook body, as sent by DERO Merchant in the X-Signature header (hex encoded).
+func SignWebhook(body, webhookSecretKey []byte) []byte {
+	mac := hmac.New(sha256.New, webhookSecretKey)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// NewWebhookRequest returns a webhook request for e, signed with the hex encoded webhookSecretKey, to be passed to a webhook handler.
+func NewWebhookRequest(url string, e *deromerchant.PaymentUpdateEvent, webhookSecretKey string) (*http.Request, error) {
+	key, err := hex.DecodeString(webhookSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature", hex.EncodeToString(SignWebhook(body, key)))
+
+	return req, nil
+}
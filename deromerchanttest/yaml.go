@@ -0,0 +1,198 @@
+package deromerchanttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML scenarios file, without indentation and comment.
+type yamlLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+// scenariosYAMLToJSON converts the YAML subset read by LoadScenariosYAML to the JSON read by LoadScenarios:
+// a top-level mapping whose values are either scalars or block sequences of mappings of scalars.
+func scenariosYAMLToJSON(r io.Reader) ([]byte, error) {
+	lines, err := readYAMLLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]interface{})
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		if l.indent != 0 {
+			return nil, yamlError(l, "unexpected indentation")
+		}
+
+		key, value, err := splitYAMLPair(l)
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		if value != "" {
+			doc[key], err = yamlScalar(l, value)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var items []interface{}
+		items, i, err = readYAMLSequence(lines, i)
+		if err != nil {
+			return nil, err
+		}
+		doc[key] = items
+	}
+
+	return json.Marshal(doc)
+}
+
+func readYAMLLines(r io.Reader) ([]yamlLine, error) {
+	var lines []yamlLine
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		raw := sc.Text()
+		if strings.Contains(raw, "\t") {
+			return nil, fmt.Errorf("deromerchanttest: invalid scenarios: line %d: tabs are not allowed in YAML", n)
+		}
+
+		text := strings.TrimRight(stripYAMLComment(raw), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (n == 1 && trimmed == "---") {
+			continue
+		}
+
+		lines = append(lines, yamlLine{n: n, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	return lines, sc.Err()
+}
+
+// stripYAMLComment removes the comment of s, if any. A comment starts with a # at the start of s or after a space, outside of quotes.
+func stripYAMLComment(s string) string {
+	var quote rune
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+
+	return s
+}
+
+// readYAMLSequence reads the block sequence of mappings starting at lines[i]. It returns its items and the index of the line after it.
+func readYAMLSequence(lines []yamlLine, i int) ([]interface{}, int, error) {
+	items := []interface{}{}
+	if i == len(lines) || lines[i].indent == 0 && !strings.HasPrefix(lines[i].text, "- ") {
+		return items, i, nil // Empty value
+	}
+
+	seqIndent := lines[i].indent
+	for i < len(lines) && lines[i].indent == seqIndent && strings.HasPrefix(lines[i].text, "- ") {
+		l := lines[i]
+
+		// The first pair of the item follows the dash, the others are aligned with it
+		first := yamlLine{n: l.n, indent: l.indent + 2, text: strings.TrimLeft(l.text[2:], " ")}
+		first.indent += len(l.text[2:]) - len(first.text)
+
+		item := make(map[string]interface{})
+		for l := first; ; {
+			key, value, err := splitYAMLPair(l)
+			if err != nil {
+				return nil, i, err
+			}
+			if _, ok := item[key]; ok {
+				return nil, i, yamlError(l, fmt.Sprintf("duplicate key %q", key))
+			}
+			item[key], err = yamlScalar(l, value)
+			if err != nil {
+				return nil, i, err
+			}
+
+			i++
+			if i == len(lines) || lines[i].indent <= seqIndent {
+				break
+			}
+			l = lines[i]
+			if l.indent != first.indent {
+				return nil, i, yamlError(l, "unexpected indentation")
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if i < len(lines) && lines[i].indent != 0 {
+		return nil, i, yamlError(lines[i], "unexpected indentation")
+	}
+
+	return items, i, nil
+}
+
+func splitYAMLPair(l yamlLine) (string, string, error) {
+	sep := strings.Index(l.text, ": ")
+	if sep == -1 && strings.HasSuffix(l.text, ":") {
+		sep = len(l.text) - 1
+	}
+	if sep <= 0 {
+		return "", "", yamlError(l, "expected key: value")
+	}
+
+	return l.text[:sep], strings.TrimSpace(l.text[sep+1:]), nil
+}
+
+// yamlScalar returns the value of the plain or quoted scalar s. Plain scalars are numbers, booleans or null if they parse as such.
+func yamlScalar(l yamlLine, s string) (interface{}, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s == "[]":
+		return []interface{}{}, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, yamlError(l, "invalid double-quoted string")
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, yamlError(l, "invalid single-quoted string")
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case strings.ContainsAny(s[:1], "[{&*!|>%@`"):
+		return nil, yamlError(l, "only plain and quoted scalars are supported")
+	case s == "null" || s == "~":
+		return nil, nil
+	case s == "true" || s == "false":
+		return s == "true", nil
+	}
+
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, nil
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	return s, nil
+}
+
+func yamlError(l yamlLine, msg string) error {
+	return fmt.Errorf("deromerchanttest: invalid scenarios: line %d: %s", l.n, msg)
+}
@@ -1,8 +1,73 @@
 package deromerchant
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Categories of errors returned by the methods of Client. Use errors.Is to check whether an error falls in one of them.
+// Both APIError and HTTPError match the category of their status code.
+var (
+	ErrUnauthorized = errors.New("DeroMerchant Client: unauthorized")
+	ErrForbidden    = errors.New("DeroMerchant Client: forbidden")
+	ErrNotFound     = errors.New("DeroMerchant Client: not found")
+	ErrRateLimited  = errors.New("DeroMerchant Client: rate limited")
+	ErrServer       = errors.New("DeroMerchant Client: server error")
+	ErrDecode       = errors.New("DeroMerchant Client: error decoding response")
+)
+
+// statusCategory returns the error category of an HTTP status code, or nil if it has none.
+func statusCategory(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrForbidden
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= 500 && statusCode <= 599:
+		return ErrServer
+	default:
+		return nil
+	}
+}
+
+// Codes of the APIError object returned by the DERO Merchant server.
+// The server sets Code to the HTTP status code of the response, so these constants only name the status codes handled by the SDK.
+// The API does not document a finer catalog: the Message of the APIError is the only description of the cause.
+const (
+	ErrorCodeBadRequest          = http.StatusBadRequest
+	ErrorCodeUnauthorized        = http.StatusUnauthorized
+	ErrorCodeForbidden           = http.StatusForbidden
+	ErrorCodeNotFound            = http.StatusNotFound
+	ErrorCodeRequestTimeout      = http.StatusRequestTimeout
+	ErrorCodeTooManyRequests     = http.StatusTooManyRequests
+	ErrorCodeInternalServerError = http.StatusInternalServerError
+	ErrorCodeBadGateway          = http.StatusBadGateway
+	ErrorCodeServiceUnavailable  = http.StatusServiceUnavailable
+	ErrorCodeGatewayTimeout      = http.StatusGatewayTimeout
+)
+
+// temporaryCode returns whether the status code reports a transient condition that is expected to clear by itself, per its HTTP semantics.
+func temporaryCode(code int) bool {
+	switch code {
+	case ErrorCodeRequestTimeout, ErrorCodeTooManyRequests, ErrorCodeBadGateway, ErrorCodeServiceUnavailable, ErrorCodeGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryableCode returns whether sending the same request again after code may succeed.
+func retryableCode(code int) bool {
+	return temporaryCode(code) || code == ErrorCodeInternalServerError
+}
 
 // APIError represents the error object returned by the server when a request fails.
+// Code is the HTTP status code of the response (see the ErrorCode constants).
 type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -11,3 +76,91 @@ type APIError struct {
 func (e *APIError) Error() string {
 	return fmt.Sprintf("DeroMerchant Client: API Error %d: %s", e.Code, e.Message)
 }
+
+// Is reports whether e falls in the error category target (e.g. ErrNotFound), or whether target is an APIError with the same Code.
+// Example: errors.Is(err, &APIError{Code: ErrorCodeNotFound})
+func (e *APIError) Is(target error) bool {
+	if t, ok := target.(*APIError); ok {
+		return t.Code == e.Code
+	}
+
+	category := statusCategory(e.Code)
+	return category != nil && category == target
+}
+
+// Temporary returns whether the error reports a transient condition (e.g. rate limiting or an unavailable server) that is expected to clear by itself.
+func (e *APIError) Temporary() bool {
+	return temporaryCode(e.Code)
+}
+
+// Retryable returns whether sending the same request again may succeed.
+// It is true for temporary errors and internal server errors.
+// Note that retrying CreatePayment after an internal server error may create a duplicate Payment.
+func (e *APIError) Retryable() bool {
+	return retryableCode(e.Code)
+}
+
+// maxHTTPErrorBodyLength is the maximum number of bytes of the response body kept in an HTTPError.
+const maxHTTPErrorBodyLength = 512
+
+// HTTPError represents an unsuccessful response of the server that does not contain an API error object (e.g. returned by a proxy).
+type HTTPError struct {
+	StatusCode int
+	URL        string
+	RequestID  string // Value of the X-Request-Id header of the response, if any.
+	Body       string // Response body, truncated to 512 bytes.
+}
+
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	if len(body) > maxHTTPErrorBodyLength {
+		body = body[:maxHTTPErrorBodyLength]
+	}
+
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL.String(),
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Body:       string(body),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	if e.StatusCode == http.StatusNotFound {
+		return fmt.Sprintf("DeroMerchant Client: error 404: page %s not found", e.URL)
+	}
+
+	return fmt.Sprintf("DeroMerchant Client: error %d returned by %s", e.StatusCode, e.URL)
+}
+
+// Is reports whether e falls in the error category target (e.g. ErrServer).
+func (e *HTTPError) Is(target error) bool {
+	category := statusCategory(e.StatusCode)
+	return category != nil && category == target
+}
+
+// Temporary returns whether the status code of the response reports a transient condition. See APIError.Temporary.
+func (e *HTTPError) Temporary() bool {
+	return temporaryCode(e.StatusCode)
+}
+
+// Retryable returns whether sending the same request again may succeed. See APIError.Retryable.
+func (e *HTTPError) Retryable() bool {
+	return retryableCode(e.StatusCode)
+}
+
+// decodeError wraps the error returned while decoding a response body. It matches ErrDecode.
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrDecode, e.err)
+}
+
+func (e *decodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,135 @@
+package deromerchant
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorCategories(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		apiError   bool
+		expected   error
+	}{
+		{http.StatusUnauthorized, true, ErrUnauthorized},
+		{http.StatusForbidden, true, ErrForbidden},
+		{http.StatusNotFound, true, ErrNotFound},
+		{http.StatusTooManyRequests, false, ErrRateLimited},
+		{http.StatusInternalServerError, true, ErrServer},
+		{http.StatusBadGateway, false, ErrServer},
+		{http.StatusNotFound, false, ErrNotFound},
+	}
+
+	for _, test := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "req-123")
+			if test.apiError {
+				err := sendErrorResponse(w, test.statusCode, http.StatusText(test.statusCode))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			w.WriteHeader(test.statusCode)
+			w.Write([]byte(strings.Repeat("x", 1000)))
+		}))
+
+		c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+		_, err = c.Ping()
+		ts.Close()
+
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected error to match %v. Got: %v\n", test.expected, err)
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) != test.apiError {
+			t.Errorf("Expected API Error: %t. Got: %v\n", test.apiError, err)
+		}
+
+		if !test.apiError {
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Errorf("Expected HTTP Error. Got: %v\n", err)
+				continue
+			}
+
+			if httpErr.StatusCode != test.statusCode || httpErr.RequestID != "req-123" || len(httpErr.Body) != maxHTTPErrorBodyLength {
+				t.Errorf("Expected HTTP Error with status %d, request ID req-123 and truncated body. Got: %+v\n", test.statusCode, httpErr)
+			}
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ping":`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	_, err = c.Ping()
+	if !errors.Is(err, ErrDecode) {
+		t.Errorf("Expected error to match %v. Got: %v\n", ErrDecode, err)
+	}
+
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected error to wrap a *json.SyntaxError. Got: %v\n", err)
+	}
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	tests := []struct {
+		code              int
+		expectTemporary   bool
+		expectRetryable   bool
+		expectedCategory  error
+		expectedCodeMatch int
+	}{
+		{ErrorCodeBadRequest, false, false, nil, ErrorCodeBadRequest},
+		{ErrorCodeUnauthorized, false, false, ErrUnauthorized, ErrorCodeUnauthorized},
+		{ErrorCodeForbidden, false, false, ErrForbidden, ErrorCodeForbidden},
+		{ErrorCodeNotFound, false, false, ErrNotFound, ErrorCodeNotFound},
+		{ErrorCodeTooManyRequests, true, true, ErrRateLimited, ErrorCodeTooManyRequests},
+		{ErrorCodeInternalServerError, false, true, ErrServer, ErrorCodeInternalServerError},
+		{ErrorCodeServiceUnavailable, true, true, ErrServer, ErrorCodeServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		var err error = &APIError{Code: test.code, Message: http.StatusText(test.code)}
+		apiErr := err.(*APIError)
+
+		if apiErr.Temporary() != test.expectTemporary {
+			t.Errorf("Expected Temporary: %t for code %d. Got: %t\n", test.expectTemporary, test.code, apiErr.Temporary())
+		}
+		if apiErr.Retryable() != test.expectRetryable {
+			t.Errorf("Expected Retryable: %t for code %d. Got: %t\n", test.expectRetryable, test.code, apiErr.Retryable())
+		}
+
+		if test.expectedCategory != nil && !errors.Is(err, test.expectedCategory) {
+			t.Errorf("Expected code %d to match %v\n", test.code, test.expectedCategory)
+		}
+		if !errors.Is(err, &APIError{Code: test.expectedCodeMatch}) {
+			t.Errorf("Expected code %d to match APIError with code %d\n", test.code, test.expectedCodeMatch)
+		}
+		if errors.Is(err, &APIError{Code: 418}) {
+			t.Errorf("Expected code %d not to match APIError with code 418\n", test.code)
+		}
+	}
+}
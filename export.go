@@ -0,0 +1,230 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the format of the files written by PaymentExporter.
+type ExportFormat int
+
+// Formats of PaymentExporter.
+const (
+	ExportCSV        ExportFormat = iota // Every field of Payment, one row per Payment.
+	ExportJSONL                          // JSON Lines: one Payment JSON object per line.
+	ExportAccounting                     // CSV with date, fiat amount, DERO amount, exchange rate and status columns, for bookkeeping.
+)
+
+// ExportOptions is a struct that holds the options of PaymentExporter and ExportPayments.
+// Only Payments created between Since (inclusive) and Until (exclusive) are exported. Zero values mean no limit.
+// StatusFilter and CurrencyFilter are passed to GetFilteredPayments by ExportPayments.
+// Times are written in Location. If not provided, it defaults to UTC.
+// Decimals is the number of decimals of fiat amounts and exchange rates in the accounting format. If nil, it defaults to 2.
+// DecimalSeparator replaces the decimal point of numbers in the CSV and accounting formats (e.g. "," for many European locales).
+// If not provided, it defaults to ".". Comma is the field delimiter of the CSV and accounting formats. If not provided, it defaults to ','.
+type ExportOptions struct {
+	Format         ExportFormat
+	Since          time.Time
+	Until          time.Time
+	StatusFilter   string
+	CurrencyFilter string
+
+	Location         *time.Location
+	Decimals         *int
+	DecimalSeparator string
+	Comma            rune
+
+	PageSize int
+}
+
+var (
+	exportCSVHeader = []string{
+		"payment_id", "status", "currency", "currency_amount", "exchange_rate", "dero_amount",
+		"atomic_dero_amount", "integrated_address", "creation_time", "ttl",
+	}
+	exportAccountingHeader = []string{
+		"date", "time", "payment_id", "status", "currency", "fiat_amount", "dero_amount", "exchange_rate",
+	}
+)
+
+// PaymentExporter writes Payments to a file in one of the ExportFormat formats.
+// Use NewPaymentExporter to create a new PaymentExporter.
+type PaymentExporter struct {
+	o        ExportOptions
+	decimals int
+	csv      *csv.Writer
+	enc      *json.Encoder
+
+	headerWritten bool
+}
+
+// NewPaymentExporter returns a new PaymentExporter writing to w. o is optional.
+func NewPaymentExporter(w io.Writer, o *ExportOptions) (*PaymentExporter, error) {
+	e := &PaymentExporter{}
+	if o != nil {
+		e.o = *o
+	}
+
+	if e.o.Location == nil {
+		e.o.Location = time.UTC
+	}
+	e.decimals = 2
+	if e.o.Decimals != nil {
+		if *e.o.Decimals < 0 {
+			return nil, fmt.Errorf("DeroMerchant: invalid number of decimals %d", *e.o.Decimals)
+		}
+		e.decimals = *e.o.Decimals
+	}
+	if e.o.DecimalSeparator == "" {
+		e.o.DecimalSeparator = "."
+	}
+	if e.o.Comma == 0 {
+		e.o.Comma = ','
+	}
+	if e.o.DecimalSeparator == string(e.o.Comma) && e.o.Format != ExportJSONL {
+		return nil, fmt.Errorf("DeroMerchant: decimal separator %q can not be the same as the field delimiter", e.o.DecimalSeparator)
+	}
+
+	switch e.o.Format {
+	case ExportCSV, ExportAccounting:
+		e.csv = csv.NewWriter(w)
+		e.csv.Comma = e.o.Comma
+	case ExportJSONL:
+		e.enc = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("DeroMerchant: unknown export format %d", e.o.Format)
+	}
+
+	return e, nil
+}
+
+// includes returns whether p was created in the time range of the exporter.
+func (e *PaymentExporter) includes(p *Payment) bool {
+	if !e.o.Since.IsZero() && p.CreationTime.Before(e.o.Since) {
+		return false
+	}
+	if !e.o.Until.IsZero() && !p.CreationTime.Before(e.o.Until) {
+		return false
+	}
+	return true
+}
+
+func (e *PaymentExporter) formatFloat(f float64, decimals int) string {
+	return strings.Replace(strconv.FormatFloat(f, 'f', decimals, 64), ".", e.o.DecimalSeparator, 1)
+}
+
+// writeHeader writes the header row of the CSV and accounting formats, once.
+func (e *PaymentExporter) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+
+	if e.o.Format == ExportAccounting {
+		return e.csv.Write(exportAccountingHeader)
+	}
+	return e.csv.Write(exportCSVHeader)
+}
+
+// Write writes p, if it was created in the time range of the exporter.
+func (e *PaymentExporter) Write(p *Payment) error {
+	if !e.includes(p) {
+		return nil
+	}
+
+	if e.enc != nil {
+		cp := *p
+		cp.CreationTime = cp.CreationTime.In(e.o.Location)
+		return e.enc.Encode(&cp)
+	}
+
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	t := p.CreationTime.In(e.o.Location)
+	deroAmount := strings.Replace(p.DeroAmount, ".", e.o.DecimalSeparator, 1)
+
+	var row []string
+	if e.o.Format == ExportAccounting {
+		row = []string{
+			t.Format("2006-01-02"),
+			t.Format("15:04:05"),
+			p.PaymentID,
+			p.Status,
+			p.Currency,
+			e.formatFloat(p.CurrencyAmount, e.decimals),
+			deroAmount,
+			e.formatFloat(p.ExchangeRate, e.decimals),
+		}
+	} else {
+		row = []string{
+			p.PaymentID,
+			p.Status,
+			p.Currency,
+			e.formatFloat(p.CurrencyAmount, -1),
+			e.formatFloat(p.ExchangeRate, -1),
+			deroAmount,
+			strconv.FormatUint(p.AtomicDeroAmount, 10),
+			p.IntegratedAddress,
+			t.Format(time.RFC3339),
+			strconv.Itoa(p.TTL),
+		}
+	}
+
+	return e.csv.Write(row)
+}
+
+// Flush writes any buffered data. It must be called once all Payments were written.
+func (e *PaymentExporter) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// ExportPayments streams the Payments listed by GetFilteredPayments to w, from newest to oldest, in the format of o. o is optional.
+// It returns the number of Payments written.
+func (c *Client) ExportPayments(ctx context.Context, w io.Writer, o *ExportOptions) (int, error) {
+	e, err := NewPaymentExporter(w, o)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err = c.eachFilteredPayment(ctx, e.o.PageSize, e.o.StatusFilter, e.o.CurrencyFilter, func(p *Payment) (bool, error) {
+		if !e.o.Since.IsZero() && p.CreationTime.Before(e.o.Since) {
+			return false, nil // Payments are listed from newest to oldest
+		}
+		if !e.includes(p) {
+			return true, nil
+		}
+
+		err := e.Write(p)
+		if err != nil {
+			return false, err
+		}
+
+		n++
+		return true, nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, e.Flush()
+}
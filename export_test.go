@@ -0,0 +1,155 @@
+package deromerchant
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExportPayments(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip("Time zone database not available")
+	}
+
+	day := time.Date(2020, 1, 29, 23, 30, 0, 0, time.UTC)
+	payments := []*Payment{
+		{PaymentID: "a", Status: PaymentStatusPaid, Currency: "EUR", CurrencyAmount: 10.5, ExchangeRate: 3.25, DeroAmount: "3.230769230769", AtomicDeroAmount: 3230769230769, CreationTime: day},
+		{PaymentID: "b", Status: PaymentStatusExpired, Currency: "USD", CurrencyAmount: 1, ExchangeRate: 3.5, DeroAmount: "0.285714285714", AtomicDeroAmount: 285714285714, CreationTime: day.Add(-time.Hour)},
+		{PaymentID: "old", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 1, CreationTime: day.AddDate(0, -1, 0)},
+		{PaymentID: "new", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 1, CreationTime: day.AddDate(0, 1, 0)},
+	}
+
+	ts := newTestPaymentsServer(t, payments)
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	since, until := day.AddDate(0, 0, -7), day.AddDate(0, 0, 7)
+
+	// Accounting format, in Rome time zone with decimal comma
+	var buf bytes.Buffer
+	n, err := c.ExportPayments(context.Background(), &buf, &ExportOptions{
+		Format:           ExportAccounting,
+		Since:            since,
+		Until:            until,
+		Location:         rome,
+		DecimalSeparator: ",",
+		Comma:            ';',
+		PageSize:         1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 payments exported. Got: %d\n", n)
+	}
+
+	r := csv.NewReader(&buf)
+	r.Comma = ';'
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		exportAccountingHeader,
+		{"2020-01-30", "00:30:00", "a", "paid", "EUR", "10,50", "3,230769230769", "3,25"},
+		{"2020-01-29", "23:30:00", "b", "expired", "USD", "1,00", "0,285714285714", "3,50"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows. Got: %v\n", len(expected), rows)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if rows[i][j] != expected[i][j] {
+				t.Errorf("Expected row %d: %v. Got: %v\n", i, expected[i], rows[i])
+				break
+			}
+		}
+	}
+
+	// JSON Lines format
+	buf.Reset()
+	_, err = c.ExportPayments(context.Background(), &buf, &ExportOptions{Format: ExportJSONL, Since: since, Until: until})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var p Payment
+		err := json.Unmarshal(s.Bytes(), &p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.PaymentID)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("Expected payments [a b]. Got: %v\n", ids)
+	}
+
+	// CSV format, every payment
+	buf.Reset()
+	n, err = c.ExportPayments(context.Background(), &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err = csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || len(rows) != 5 || len(rows[0]) != len(exportCSVHeader) {
+		t.Errorf("Expected header and 4 rows of %d columns. Got: %v\n", len(exportCSVHeader), rows)
+	}
+
+	// Accounting format without decimals
+	buf.Reset()
+	zero := 0
+	_, err = c.ExportPayments(context.Background(), &buf, &ExportOptions{Format: ExportAccounting, Since: since, Until: until, Decimals: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err = csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][5] != "10" || rows[1][7] != "3" {
+		t.Errorf("Expected amounts without decimals. Got: %v\n", rows)
+	}
+
+	// Payments not written are not counted
+	n, err = c.ExportPayments(context.Background(), failingWriter{}, &ExportOptions{Format: ExportJSONL})
+	if err != errWriteFailed {
+		t.Errorf("Expected error: %v. Got: %v\n", errWriteFailed, err)
+	}
+	if n != 0 {
+		t.Errorf("Expected 0 payments exported. Got: %d\n", n)
+	}
+
+	// Decimal separator and field delimiter can not be the same
+	_, err = NewPaymentExporter(&buf, &ExportOptions{DecimalSeparator: ","})
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
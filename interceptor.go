@@ -0,0 +1,98 @@
+package deromerchant
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Call is a request of the API going through the interceptors of a Client.
+//
+// Before an interceptor calls the next Doer, Request has the X-API-Key header set by NewRequest (the API Key of ClientOptions)
+// but no signature headers, and Result is not decoded.
+// After it returns, Request holds the X-API-Key of the credentials the request was sent with (see WithCredentialsProvider)
+// and its signature headers, Result the decoded response, and Response the metadata of the response (nil if none was received).
+// Each call of the next Doer signs Request again, so retries are sent with a fresh SignatureV2 timestamp and nonce.
+// Interceptors may modify Request (e.g. add headers) before calling the next Doer, and may call it more than once (e.g. to retry).
+type Call struct {
+	Request  *http.Request
+	Signed   bool        // Whether Request is signed: always by SendSignedRequest, and by SendRequest with SignatureV2.
+	Result   interface{} // Pointer the response body is decoded into. It may be nil.
+	Response *Response
+
+	sent bool
+}
+
+// Doer sends a Call.
+type Doer interface {
+	Do(call *Call) error
+}
+
+// DoerFunc is an adapter to use an ordinary function as a Doer.
+type DoerFunc func(call *Call) error
+
+// Do calls f(call).
+func (f DoerFunc) Do(call *Call) error {
+	return f(call)
+}
+
+// Interceptor wraps the Doer that sets credentials, signs and sends the requests of a Client (see WithInterceptors).
+// Logging, metrics, retries or fault injection can be written as interceptors.
+type Interceptor func(next Doer) Doer
+
+// WithInterceptors adds interceptors around SendRequest and SendSignedRequest, and so around every method of the Client.
+// Interceptors run in order: the first one sees the Call first and its result last.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) error {
+		for _, i := range interceptors {
+			if i == nil {
+				return errors.New("DeroMerchant Client: nil interceptor")
+			}
+		}
+
+		c.interceptors = append(c.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// doIntercepted sends req through the interceptors of the Client, if any.
+func (c *Client) doIntercepted(req *http.Request, respBody interface{}, signed bool) error {
+	if len(c.interceptors) == 0 {
+		return c.do(req, respBody, signed)
+	}
+
+	var d Doer = DoerFunc(c.doCall)
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		d = c.interceptors[i](d)
+	}
+
+	return d.Do(&Call{
+		Request: req,
+		Signed:  signed,
+		Result:  respBody,
+	})
+}
+
+// doCall is the innermost Doer of the interceptor chain.
+func (c *Client) doCall(call *Call) error {
+	req := call.Request
+
+	if call.sent && req.GetBody != nil {
+		body, err := req.GetBody() // Make body readable again, for interceptors sending a Call more than once
+		if err != nil {
+			return err
+		}
+		req.Body = body
+	}
+	call.sent = true
+
+	// Each send gets its own Response, copied to the one of the context (if any) once received,
+	// so that concurrent calls sharing a context do not see each other's Response
+	resp := &Response{}
+	dst := responseFromContext(req.Context())
+	err := c.do(req.WithContext(ContextWithResponse(req.Context(), resp)), call.Result, call.Signed)
+	call.Response = withResponse(resp)
+	if dst != nil && call.Response != nil {
+		dst.store(call.Response)
+	}
+	return err
+}
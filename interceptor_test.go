@@ -0,0 +1,173 @@
+package deromerchant
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInterceptors(t *testing.T) {
+	failures := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := signMessage(body, mustParseSecretKey(t, secretKey).key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Header.Get("X-Signature") != hex.EncodeToString(s) {
+			err := sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"paymentID": "a", "currency": "DERO"}`))
+	}))
+	defer ts.Close()
+
+	var trace []string
+	logging := func(next Doer) Doer {
+		return DoerFunc(func(call *Call) error {
+			trace = append(trace, "log: signed before: "+strconv.FormatBool(call.Request.Header.Get("X-Signature") != ""))
+
+			err := next.Do(call)
+			trace = append(trace, "log: signed after: "+strconv.FormatBool(call.Request.Header.Get("X-Signature") != ""))
+
+			if p, ok := call.Result.(**Payment); ok && *p != nil {
+				trace = append(trace, "log: decoded "+(*p).PaymentID)
+			}
+			if call.Response != nil {
+				trace = append(trace, "log: status "+http.StatusText(call.Response.StatusCode))
+			}
+			return err
+		})
+	}
+	retry := func(next Doer) Doer {
+		return DoerFunc(func(call *Call) error {
+			err := next.Do(call)
+			if errors.Is(err, ErrServer) {
+				trace = append(trace, "retry")
+				err = next.Do(call)
+			}
+			return err
+		})
+	}
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithBaseURL(ts.URL), WithInterceptors(logging, retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.CreatePayment("DERO", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentID != "a" {
+		t.Errorf("Expected Payment ID: a. Got: %s\n", p.PaymentID)
+	}
+
+	expected := []string{
+		"log: signed before: false",
+		"retry",
+		"log: signed after: true",
+		"log: decoded a",
+		"log: status Created",
+	}
+	if len(trace) != len(expected) {
+		t.Fatalf("Expected trace:\n%v\nGot:\n%v\n", expected, trace)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Errorf("Expected trace:\n%v\nGot:\n%v\n", expected, trace)
+			break
+		}
+	}
+
+	// Fault injection
+	errInjected := errors.New("injected")
+	c, err = NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithBaseURL(ts.URL), WithInterceptors(func(next Doer) Doer {
+		return DoerFunc(func(call *Call) error {
+			return errInjected
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Ping()
+	if err != errInjected {
+		t.Errorf("Expected error: %v. Got: %v\n", errInjected, err)
+	}
+}
+
+func TestInterceptorRetrySignatureV2(t *testing.T) {
+	clk := &manualClock{now: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)}
+	key := mustParseSecretKey(t, secretKey)
+
+	var (
+		attempts int
+		nonces   = make(map[string]bool)
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		// Replayed nonces and timestamps more than a minute old are refused
+		nonce := r.Header.Get(SignatureNonceHeader)
+		err := VerifyRequestSignatureAt(r, key, time.Minute, clk.Now())
+		if err != nil || nonces[nonce] {
+			t.Errorf("Expected attempt %d to be signed again. Got error: %v, replayed nonce: %t\n", attempts, err, nonces[nonce])
+			sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		nonces[nonce] = true
+
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ping": "pong"}`))
+	}))
+	defer ts.Close()
+
+	retry := func(next Doer) Doer {
+		return DoerFunc(func(call *Call) error {
+			err := next.Do(call)
+			if errors.Is(err, ErrServer) {
+				clk.Advance(5 * time.Minute) // Backoff long enough for the first signature to expire
+				err = next.Do(call)
+			}
+			return err
+		})
+	}
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey, SignatureVersion: SignatureV2},
+		WithBaseURL(ts.URL), WithClock(clk), WithInterceptors(retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts. Got: %d\n", attempts)
+	}
+}
@@ -0,0 +1,157 @@
+package deromerchant
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Format     string                    `json:"format"`
+	Enum       []string                  `json:"enum"`
+	Required   []string                  `json:"required"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+}
+
+// TestOpenAPIContract checks that the structs of the SDK match the schemas of openapi.json.
+func TestOpenAPIContract(t *testing.T) {
+	b, err := ioutil.ReadFile("openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spec struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]*openAPISchema `json:"schemas"`
+		} `json:"components"`
+	}
+	err = json.Unmarshal(b, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		schema string
+		v      interface{}
+	}{
+		{schema: "PingResponse", v: PingResponse{}},
+		{schema: "CreatePaymentRequest", v: createPaymentRequest{}},
+		{schema: "Payment", v: Payment{}},
+		{schema: "GetFilteredPaymentsResponse", v: GetFilteredPaymentsResponse{}},
+		{schema: "APIError", v: APIError{}},
+		{schema: "ErrorResponse", v: errorResponse{}},
+		{schema: "PaymentUpdateEvent", v: PaymentUpdateEvent{}},
+	}
+
+	for _, test := range tests {
+		s, ok := spec.Components.Schemas[test.schema]
+		if !ok {
+			t.Errorf("Schema %s not found\n", test.schema)
+			continue
+		}
+
+		checkSchema(t, spec.Components.Schemas, test.schema, s, reflect.TypeOf(test.v))
+	}
+
+	status := spec.Components.Schemas["PaymentStatus"]
+	expectedStatuses := []string{PaymentStatusPending, PaymentStatusPaid, PaymentStatusExpired, PaymentStatusError}
+	if status == nil || !reflect.DeepEqual(status.Enum, expectedStatuses) {
+		t.Errorf("Expected PaymentStatus enum: %v\n", expectedStatuses)
+	}
+}
+
+func checkSchema(t *testing.T, schemas map[string]*openAPISchema, name string, s *openAPISchema, typ reflect.Type) {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || tag == "-" || tag == "" {
+			continue
+		}
+		fields[tag] = f
+	}
+
+	for prop := range s.Properties {
+		if _, ok := fields[prop]; !ok {
+			t.Errorf("%s: property %s of schema has no field in %s\n", name, prop, typ)
+		}
+	}
+	for _, prop := range s.Required {
+		if _, ok := s.Properties[prop]; !ok {
+			t.Errorf("%s: required property %s is not defined\n", name, prop)
+		}
+	}
+
+	for tag, f := range fields {
+		prop, ok := s.Properties[tag]
+		if !ok {
+			t.Errorf("%s: field %s of %s is not in schema\n", name, f.Name, typ)
+			continue
+		}
+
+		checkType(t, schemas, name+"."+tag, prop, f.Type)
+	}
+}
+
+func checkType(t *testing.T, schemas map[string]*openAPISchema, name string, s *openAPISchema, typ reflect.Type) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if s.Ref != "" {
+		ref := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		resolved, ok := schemas[ref]
+		if !ok {
+			t.Errorf("%s: unresolved reference %s\n", name, s.Ref)
+			return
+		}
+		if resolved.Type == "object" && ref != typ.Name() && !strings.EqualFold(ref, typ.Name()) {
+			t.Errorf("%s: expected reference to %s. Got: %s\n", name, typ.Name(), ref)
+		}
+		s = resolved
+	}
+
+	var expected string
+	switch {
+	case typ == reflect.TypeOf(time.Time{}):
+		if s.Type != "string" || s.Format != "date-time" {
+			t.Errorf("%s: expected date-time string. Got: %s %s\n", name, s.Type, s.Format)
+		}
+		return
+	case typ.Kind() == reflect.String:
+		expected = "string"
+	case typ.Kind() == reflect.Bool:
+		expected = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		expected = "integer"
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		expected = "number"
+	case typ.Kind() == reflect.Slice:
+		expected = "array"
+	case typ.Kind() == reflect.Struct:
+		expected = "object"
+	}
+
+	if s.Type != expected {
+		t.Errorf("%s: expected type %s for %s. Got: %s\n", name, expected, typ, s.Type)
+		return
+	}
+
+	switch expected {
+	case "array":
+		if s.Items == nil {
+			t.Errorf("%s: array without items\n", name)
+			return
+		}
+		checkType(t, schemas, name+"[]", s.Items, typ.Elem())
+	case "object":
+		checkSchema(t, schemas, name, s, typ)
+	}
+}
@@ -0,0 +1,150 @@
+package deromerchant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Option is a functional option that configures a Client. Options are passed to NewClient and applied in order, after ClientOptions.
+type Option func(c *Client) error
+
+// WithHTTPClient makes the Client send requests with hc instead of its default http.Client.
+// Options WithTimeout and WithTransport passed after WithHTTPClient apply to a copy of hc, leaving hc untouched.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("DeroMerchant Client: nil HTTP client")
+		}
+
+		c.HTTPClient = hc
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of the requests sent by the Client. Default is 10 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d < 0 {
+			return fmt.Errorf("DeroMerchant Client: invalid timeout %v", d)
+		}
+
+		hc := *c.HTTPClient
+		hc.Timeout = d
+		c.HTTPClient = &hc
+		return nil
+	}
+}
+
+// WithTransport sets the http.RoundTripper used by the http.Client of the Client.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		hc := *c.HTTPClient
+		hc.Transport = rt
+		c.HTTPClient = &hc
+		return nil
+	}
+}
+
+// WithBaseURL overrides the base URL of the API (Scheme, Host and APIVersion of ClientOptions).
+// Example: http://localhost:8080/api/v1
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		u, err := url.ParseRequestURI(baseURL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("DeroMerchant Client: invalid base URL %s", baseURL)
+		}
+
+		c.scheme = u.Scheme
+		c.host = u.Host
+		c.baseURL = strings.TrimRight(baseURL, "/")
+
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(segments) == 2 && segments[0] == "api" {
+			c.apiVersion = segments[1]
+		}
+
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests sent by the Client. Default is DeroMerchant_Client_Golang/1.0.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		if userAgent == "" {
+			return errors.New("DeroMerchant Client: empty user agent")
+		}
+
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithSecretKey sets the Secret Key of the Client, overriding the SecretKey field of ClientOptions.
+// The Client uses k directly: zeroing it with k.Zero makes signed requests fail with ErrSecretKeyZeroed.
+func WithSecretKey(k *SecretKey) Option {
+	return func(c *Client) error {
+		if k == nil {
+			return errors.New("DeroMerchant Client: nil secret key")
+		}
+
+		c.secretKey = k
+		return nil
+	}
+}
+
+// Environment variables read by NewClientFromEnv.
+const (
+	EnvAPIKey     = "DERO_MERCHANT_API_KEY"
+	EnvSecretKey  = "DERO_MERCHANT_SECRET_KEY"
+	EnvScheme     = "DERO_MERCHANT_SCHEME"
+	EnvHost       = "DERO_MERCHANT_HOST"
+	EnvAPIVersion = "DERO_MERCHANT_API_VERSION"
+	EnvBaseURL    = "DERO_MERCHANT_BASE_URL"
+	EnvTimeout    = "DERO_MERCHANT_TIMEOUT"
+	EnvUserAgent  = "DERO_MERCHANT_USER_AGENT"
+)
+
+// NewClientFromEnv returns a new Client configured from the DERO_MERCHANT_* environment variables.
+// DERO_MERCHANT_API_KEY and DERO_MERCHANT_SECRET_KEY are required. DERO_MERCHANT_TIMEOUT is parsed with time.ParseDuration (e.g. 5s).
+// Options passed as arguments are applied after the ones read from the environment.
+func NewClientFromEnv(opts ...Option) (*Client, error) {
+	o := &ClientOptions{
+		Scheme:     os.Getenv(EnvScheme),
+		Host:       os.Getenv(EnvHost),
+		APIVersion: os.Getenv(EnvAPIVersion),
+		APIKey:     os.Getenv(EnvAPIKey),
+		SecretKey:  os.Getenv(EnvSecretKey),
+	}
+
+	if o.APIKey == "" {
+		return nil, fmt.Errorf("DeroMerchant Client: environment variable %s not set", EnvAPIKey)
+	}
+	if o.SecretKey == "" {
+		return nil, fmt.Errorf("DeroMerchant Client: environment variable %s not set", EnvSecretKey)
+	}
+
+	var envOpts []Option
+	if v := os.Getenv(EnvBaseURL); v != "" {
+		envOpts = append(envOpts, WithBaseURL(v))
+	}
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("DeroMerchant Client: invalid %s: %v", EnvTimeout, err)
+		}
+		envOpts = append(envOpts, WithTimeout(d))
+	}
+	if v := os.Getenv(EnvUserAgent); v != "" {
+		envOpts = append(envOpts, WithUserAgent(v))
+	}
+
+	return NewClient(o, append(envOpts, opts...)...)
+}
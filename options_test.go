@@ -0,0 +1,129 @@
+package deromerchant
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewClientOptions(t *testing.T) {
+	hc := &http.Client{Timeout: time.Minute}
+
+	c, err := NewClient(&ClientOptions{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+	}, WithHTTPClient(hc), WithTimeout(time.Second), WithUserAgent("MyStore/2.0"), WithBaseURL("http://localhost:8080/api/v2/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.HTTPClient.Timeout != time.Second {
+		t.Errorf("Expected timeout: %v. Got: %v\n", time.Second, c.HTTPClient.Timeout)
+	}
+	if hc.Timeout != time.Minute {
+		t.Errorf("Expected HTTP client passed to WithHTTPClient not to be modified. Got timeout: %v\n", hc.Timeout)
+	}
+
+	if c.baseURL != "http://localhost:8080/api/v2" || c.scheme != "http" || c.host != "localhost:8080" || c.apiVersion != "v2" {
+		t.Errorf("Expected base URL http://localhost:8080/api/v2. Got: %s (scheme: %s, host: %s, API version: %s)\n", c.baseURL, c.scheme, c.host, c.apiVersion)
+	}
+
+	req, err := c.NewRequest(http.MethodGet, "/ping", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := req.Header.Get("User-Agent"); h != "MyStore/2.0" {
+		t.Errorf("Expected header User-Agent: MyStore/2.0. Got: %s\n", h)
+	}
+
+	tests := []struct {
+		options *ClientOptions
+		opts    []Option
+	}{
+		{&ClientOptions{APIKey: apiKey, SecretKey: "not hex"}, nil},
+		{&ClientOptions{APIKey: "", SecretKey: secretKey}, nil},
+		{&ClientOptions{APIKey: apiKey, SecretKey: ""}, nil},
+		{nil, nil},
+		{&ClientOptions{APIKey: "api key\n", SecretKey: secretKey}, nil},
+		{&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, []Option{WithBaseURL("localhost")}},
+		{&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, []Option{WithTimeout(-time.Second)}},
+		{&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, []Option{WithHTTPClient(nil)}},
+	}
+
+	for _, test := range tests {
+		c, err := NewClient(test.options, test.opts...)
+		if err == nil {
+			t.Errorf("Expected error. Got Client: %+v\n", c)
+		}
+	}
+}
+
+func TestNewClientKeys(t *testing.T) {
+	k, err := ParseSecretKey(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Secret Key set by WithSecretKey
+	c, err := NewClient(&ClientOptions{APIKey: apiKey}, WithSecretKey(k))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.secretKey != k {
+		t.Error("Expected Secret Key set by WithSecretKey")
+	}
+
+	// Keys provided by a CredentialsProvider
+	_, err = NewClient(nil, WithCredentialsProvider(StaticCredentials{{APIKey: apiKey, SecretKey: k}}))
+	if err != nil {
+		t.Errorf("Error not expected. Got: %v\n", err)
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	env := map[string]string{
+		EnvAPIKey:    apiKey,
+		EnvSecretKey: secretKey,
+		EnvBaseURL:   "http://localhost:8080/api/v1",
+		EnvTimeout:   "3s",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	c, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.apiKey != apiKey {
+		t.Errorf("Expected API key: %s. Got: %s\n", apiKey, c.apiKey)
+	}
+	if c.baseURL != env[EnvBaseURL] {
+		t.Errorf("Expected base URL: %s. Got: %s\n", env[EnvBaseURL], c.baseURL)
+	}
+	if c.HTTPClient.Timeout != 3*time.Second {
+		t.Errorf("Expected timeout: %v. Got: %v\n", 3*time.Second, c.HTTPClient.Timeout)
+	}
+
+	os.Setenv(EnvTimeout, "three seconds")
+	_, err = NewClientFromEnv()
+	if err == nil {
+		t.Error("Expected error")
+	}
+
+	os.Unsetenv(EnvTimeout)
+	os.Unsetenv(EnvSecretKey)
+	_, err = NewClientFromEnv()
+	if err == nil {
+		t.Error("Expected error")
+	}
+
+	os.Unsetenv(EnvAPIKey)
+	_, err = NewClientFromEnv()
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
@@ -1,8 +1,12 @@
 package deromerchant
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -19,6 +23,30 @@ type Payment struct {
 	IntegratedAddress string    `json:"integratedAddress"`
 	CreationTime      time.Time `json:"creationTime"`
 	TTL               int       `json:"ttl"`
+
+	// Extra holds the fields sent by the server unknown to the SDK, if the Client decodes responses with DecodeLenient.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	fetchedAt time.Time     // Server time the Payment was fetched at
+	skew      time.Duration // Clock skew measured by the Client that fetched the Payment
+}
+
+// Statuses of a Payment.
+const (
+	PaymentStatusPending = "pending"
+	PaymentStatusPaid    = "paid"
+	PaymentStatusExpired = "expired"
+	PaymentStatusError   = "error"
+)
+
+// IsFinal returns whether the status of the Payment can not change anymore.
+func (p *Payment) IsFinal() bool {
+	switch p.Status {
+	case PaymentStatusPaid, PaymentStatusExpired, PaymentStatusError:
+		return true
+	default:
+		return false
+	}
 }
 
 type createPaymentRequest struct {
@@ -30,12 +58,17 @@ type createPaymentRequest struct {
 // It is used to create a new Payment on the DERO Merchant server and receive its details.
 // Function can return an APIError if the request makes it to the server but something goes wrong.
 func (c *Client) CreatePayment(currency string, amount float64) (*Payment, error) {
+	return c.CreatePaymentWithContext(context.Background(), currency, amount)
+}
+
+// CreatePaymentWithContext is like CreatePayment but sends the request with ctx.
+func (c *Client) CreatePaymentWithContext(ctx context.Context, currency string, amount float64) (*Payment, error) {
 	payload := &createPaymentRequest{
 		Currency: currency,
 		Amount:   amount,
 	}
 
-	req, err := c.NewRequest(http.MethodPost, "/payment", nil, payload)
+	req, err := c.NewRequestWithContext(ctx, http.MethodPost, "/payment", nil, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -43,13 +76,9 @@ func (c *Client) CreatePayment(currency string, amount float64) (*Payment, error
 	var resp *Payment
 	err = c.SendSignedRequest(req, &resp)
 	if err != nil {
-		apiErr, ok := err.(*APIError)
-		if ok {
-			return nil, apiErr
-		}
-
 		return nil, err
 	}
+	c.stampPayments(resp)
 
 	return resp, nil
 }
@@ -58,8 +87,26 @@ func (c *Client) CreatePayment(currency string, amount float64) (*Payment, error
 // It is used to get a Payment's details from its Payment ID from the DERO Merchant server.
 // Function can return an APIError if the request makes it to the server but something goes wrong.
 func (c *Client) GetPayment(paymentID string) (*Payment, error) {
+	return c.GetPaymentWithContext(context.Background(), paymentID)
+}
+
+// GetPaymentWithContext is like GetPayment but sends the request with ctx.
+// Concurrent calls for the same Payment ID are merged into a single request, sent with the context values of the first caller only
+// (e.g. its TraceContext, or the values read by a CredentialsProvider).
+func (c *Client) GetPaymentWithContext(ctx context.Context, paymentID string) (*Payment, error) {
+	v, err := c.coalesce(ctx, "GetPayment "+paymentID, func(ctx context.Context) (interface{}, error) {
+		return c.getPayment(ctx, paymentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyPayment(v.(*Payment)), nil
+}
+
+func (c *Client) getPayment(ctx context.Context, paymentID string) (*Payment, error) {
 	endpoint := fmt.Sprintf("/payment/%s", paymentID)
-	req, err := c.NewRequest(http.MethodGet, endpoint, nil, nil)
+	req, err := c.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -67,13 +114,9 @@ func (c *Client) GetPayment(paymentID string) (*Payment, error) {
 	var resp *Payment
 	err = c.SendRequest(req, &resp)
 	if err != nil {
-		apiErr, ok := err.(*APIError)
-		if ok {
-			return nil, apiErr
-		}
-
 		return nil, err
 	}
+	c.stampPayments(resp)
 
 	return resp, nil
 }
@@ -82,7 +125,46 @@ func (c *Client) GetPayment(paymentID string) (*Payment, error) {
 // It is used to get multiple Payments' details from their Paymnet IDs from the DERO Merchant server.
 // Function can return an APIError if the request makes it to the server but something goes wrong.
 func (c *Client) GetPayments(paymentIDs []string) ([]*Payment, error) {
-	req, err := c.NewRequest(http.MethodPost, "/payments", nil, paymentIDs)
+	return c.GetPaymentsWithContext(context.Background(), paymentIDs)
+}
+
+// GetPaymentsWithContext is like GetPayments but sends the request with ctx.
+// Concurrent calls for the same set of Payment IDs, in any order and ignoring duplicates, are merged into a single request,
+// sent with the list and the context values of the first caller only (see GetPaymentWithContext): merged callers get the Payments in the order
+// the server returned them for that list. Calls for overlapping but different sets are not merged.
+// The whole list is sent in a single request. Use GetPaymentsBatch to split long lists into several requests.
+func (c *Client) GetPaymentsWithContext(ctx context.Context, paymentIDs []string) ([]*Payment, error) {
+	return c.getPaymentsCoalesced(ctx, paymentIDs)
+}
+
+func (c *Client) getPaymentsCoalesced(ctx context.Context, paymentIDs []string) ([]*Payment, error) {
+	v, err := c.coalesce(ctx, "GetPayments "+paymentIDsKey(paymentIDs), func(ctx context.Context) (interface{}, error) {
+		return c.getPayments(ctx, paymentIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return copyPayments(v.([]*Payment)), nil
+}
+
+// paymentIDsKey returns the sorted and deduplicated paymentIDs, joined by commas.
+func paymentIDsKey(paymentIDs []string) string {
+	ids := append([]string(nil), paymentIDs...)
+	sort.Strings(ids)
+
+	unique := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+
+	return strings.Join(unique, ",")
+}
+
+func (c *Client) getPayments(ctx context.Context, paymentIDs []string) ([]*Payment, error) {
+	req, err := c.NewRequestWithContext(ctx, http.MethodPost, "/payments", nil, paymentIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +172,9 @@ func (c *Client) GetPayments(paymentIDs []string) ([]*Payment, error) {
 	var resp []*Payment
 	err = c.SendRequest(req, &resp)
 	if err != nil {
-		apiErr, ok := err.(*APIError)
-		if ok {
-			return nil, apiErr
-		}
-
 		return nil, err
 	}
+	c.stampPayments(resp...)
 
 	return resp, nil
 }
@@ -115,6 +193,11 @@ type GetFilteredPaymentsResponse struct {
 // It gets multiple Payments' details based on filters from the DERO Merchant server.
 // Function can return an APIError if the request makes it to the server but something goes wrong.
 func (c *Client) GetFilteredPayments(limit, page int, sortBy, orderBy, statusFilter, currencyFilter string) (*GetFilteredPaymentsResponse, error) {
+	return c.GetFilteredPaymentsWithContext(context.Background(), limit, page, sortBy, orderBy, statusFilter, currencyFilter)
+}
+
+// GetFilteredPaymentsWithContext is like GetFilteredPayments but sends the request with ctx.
+func (c *Client) GetFilteredPaymentsWithContext(ctx context.Context, limit, page int, sortBy, orderBy, statusFilter, currencyFilter string) (*GetFilteredPaymentsResponse, error) {
 	queryParams := map[string]interface{}{
 		"limit":    limit,
 		"page":     page,
@@ -124,7 +207,7 @@ func (c *Client) GetFilteredPayments(limit, page int, sortBy, orderBy, statusFil
 		"currency": currencyFilter,
 	}
 
-	req, err := c.NewRequest(http.MethodGet, "/payments", queryParams, nil)
+	req, err := c.NewRequestWithContext(ctx, http.MethodGet, "/payments", queryParams, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -132,13 +215,11 @@ func (c *Client) GetFilteredPayments(limit, page int, sortBy, orderBy, statusFil
 	var resp *GetFilteredPaymentsResponse
 	err = c.SendRequest(req, &resp)
 	if err != nil {
-		apiErr, ok := err.(*APIError)
-		if ok {
-			return nil, apiErr
-		}
-
 		return nil, err
 	}
+	if resp != nil {
+		c.stampPayments(resp.Payments...)
+	}
 
 	return resp, nil
 }
@@ -169,8 +169,15 @@ func TestGetPayment(t *testing.T) {
 
 	for _, test := range tests {
 		c, err := NewClient(&ClientOptions{
-			APIKey: test.apiKey,
+			APIKey:    test.apiKey,
+			SecretKey: validSecretKey,
 		})
+		if test.apiKey == "" {
+			if err == nil {
+				t.Error("Expected error for empty API key")
+			}
+			continue // Rejected by NewClient before any request is sent
+		}
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -263,8 +270,15 @@ func TestGetPayments(t *testing.T) {
 
 	for _, test := range tests {
 		c, err := NewClient(&ClientOptions{
-			APIKey: test.apiKey,
+			APIKey:    test.apiKey,
+			SecretKey: validSecretKey,
 		})
+		if test.apiKey == "" {
+			if err == nil {
+				t.Error("Expected error for empty API key")
+			}
+			continue // Rejected by NewClient before any request is sent
+		}
 		if err != nil {
 			t.Fatal(err)
 		}
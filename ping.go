@@ -1,6 +1,7 @@
 package deromerchant
 
 import (
+	"context"
 	"net/http"
 )
 
@@ -12,7 +13,12 @@ type PingResponse struct {
 // Ping sends a GET request to the /ping endpoint and returns the response as a PingResponse.
 // It is used to check whether server is online or offline. Is the second case, it may also be due to bad Scheme/Host/APIVersion client options.
 func (c *Client) Ping() (*PingResponse, error) {
-	req, err := c.NewRequest(http.MethodGet, "/ping", nil, nil)
+	return c.PingWithContext(context.Background())
+}
+
+// PingWithContext is like Ping but sends the request with ctx.
+func (c *Client) PingWithContext(ctx context.Context) (*PingResponse, error) {
+	req, err := c.NewRequestWithContext(ctx, http.MethodGet, "/ping", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -55,8 +55,15 @@ func TestPing(t *testing.T) {
 
 	for _, test := range tests {
 		c, err := NewClient(&ClientOptions{
-			APIKey: test.apiKey,
+			APIKey:    test.apiKey,
+			SecretKey: secretKey,
 		})
+		if test.apiKey == "" {
+			if err == nil {
+				t.Error("Expected error for empty API key")
+			}
+			continue // Rejected by NewClient before any request is sent
+		}
 		if err != nil {
 			t.Fatal(err)
 		}
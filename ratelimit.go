@@ -0,0 +1,203 @@
+package deromerchant
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions is a struct that holds the options of the client-side rate limiter.
+// RateLimitOptions have to be passed as the RateLimit field of ClientOptions.
+// Requests are limited with a token bucket refilled at RequestsPerSecond, holding up to Burst tokens.
+// If Burst is not provided, it defaults to 1.
+// If RequestsPerSecond is 0 or less, requests are not limited client-side: only 429 responses and server quotas
+// sent in X-RateLimit-* headers hold requests back.
+type RateLimitOptions struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// RateLimitStats is a struct that holds the current state of the client-side rate limiter.
+// Server fields are filled from the X-RateLimit-* headers of the last response, if sent by the server.
+type RateLimitStats struct {
+	RequestsPerSecond float64
+	Burst             int
+	Available         float64   // Tokens currently available in the bucket.
+	PausedUntil       time.Time // Requests are held until this time after a 429 response or an exhausted server quota.
+	Throttled         int       // Number of 429 responses received.
+
+	ServerLimit     int
+	ServerRemaining int
+	ServerReset     time.Time
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+	stats  RateLimitStats
+	clock  Clock
+}
+
+func newRateLimiter(o *RateLimitOptions, clock Clock) *rateLimiter {
+	burst := o.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   o.RequestsPerSecond,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   clock.Now(),
+		stats: RateLimitStats{
+			ServerLimit:     -1,
+			ServerRemaining: -1,
+		},
+		clock: clock,
+	}
+}
+
+// refill must be called with l.mu held.
+func (l *rateLimiter) refill(now time.Time) {
+	if l.rate > 0 {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(float64(l.burst), l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+}
+
+// reserve takes a token if one is available. Otherwise it returns how long to wait before trying again.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.refill(now)
+
+	if now.Before(l.stats.PausedUntil) {
+		return l.stats.PausedUntil.Sub(now)
+	}
+
+	if l.rate <= 0 {
+		return 0 // No client-side limit
+	}
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Wait blocks until a request can be sent or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		d := l.reserve()
+		if d <= 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(d):
+		}
+	}
+}
+
+// Observe adjusts the limiter to the rate limit information sent by the server in resp.
+func (l *rateLimiter) Observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+
+	if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil {
+		l.stats.ServerLimit = v
+	}
+	if reset, ok := parseRateLimitReset(resp.Header.Get("X-RateLimit-Reset"), now); ok {
+		l.stats.ServerReset = reset
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		l.stats.ServerRemaining = v
+		if float64(v) < l.tokens {
+			l.tokens = float64(v)
+		}
+		if v == 0 && l.stats.ServerReset.After(l.stats.PausedUntil) {
+			l.stats.PausedUntil = l.stats.ServerReset
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.stats.Throttled++
+		l.tokens = 0
+
+		pause, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+		if !ok {
+			pause = now.Add(time.Second)
+		}
+		if pause.After(l.stats.PausedUntil) {
+			l.stats.PausedUntil = pause
+		}
+	}
+}
+
+func (l *rateLimiter) Stats() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(l.clock.Now())
+
+	s := l.stats
+	s.RequestsPerSecond = l.rate
+	s.Burst = l.burst
+	s.Available = l.tokens
+	return s
+}
+
+// parseRetryAfter parses a Retry-After header, expressed either in seconds or as an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return now.Add(time.Duration(secs) * time.Second), true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseRateLimitReset parses a X-RateLimit-Reset header, expressed either in seconds from now or as a Unix timestamp.
+func parseRateLimitReset(v string, now time.Time) (time.Time, bool) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if n > 1e9 {
+		return time.Unix(n, 0), true
+	}
+	return now.Add(time.Duration(n) * time.Second), true
+}
+
+// RateLimitStats returns the current state of the client-side rate limiter.
+// It returns the zero value if the Client was created without RateLimitOptions.
+func (c *Client) RateLimitStats() RateLimitStats {
+	if c.limiter == nil {
+		return RateLimitStats{}
+	}
+
+	return c.limiter.Stats()
+}
@@ -0,0 +1,135 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	throttle := false
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "100")
+		if throttle {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "1")
+			err := sendErrorResponse(w, http.StatusTooManyRequests, "Too Many Requests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Remaining", "99")
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		RateLimit: &RateLimitOptions{RequestsPerSecond: 50, Burst: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	// Burst of 1 at 50 requests per second: 3 requests take at least 40ms
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.Ping()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Expected requests to be rate limited. 3 requests took %v\n", elapsed)
+	}
+
+	stats := c.RateLimitStats()
+	if stats.ServerLimit != 100 || stats.ServerRemaining != 99 {
+		t.Errorf("Expected server limit 100 and remaining 99. Got: %d and %d\n", stats.ServerLimit, stats.ServerRemaining)
+	}
+
+	// 429 response pauses the limiter for the duration of Retry-After
+	throttle = true
+	_, err = c.Ping()
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	stats = c.RateLimitStats()
+	if stats.Throttled != 1 {
+		t.Errorf("Expected 1 throttled request. Got: %d\n", stats.Throttled)
+	}
+	if !stats.PausedUntil.After(time.Now()) {
+		t.Errorf("Expected limiter to be paused. Got paused until: %v\n", stats.PausedUntil)
+	}
+
+	// Waiting callers give up when their context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = c.PingWithContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error: %v. Got: %v\n", context.DeadlineExceeded, err)
+	}
+}
+
+func TestRateLimiterNoClientLimit(t *testing.T) {
+	l := newRateLimiter(&RateLimitOptions{RequestsPerSecond: 0, Burst: 1}, systemClock{})
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 10; i++ {
+			err := l.Wait(context.Background()) // No deadline
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected requests not to be limited client-side with RequestsPerSecond 0")
+	}
+
+	// Server quotas are still honored
+	l.Observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"1"}}})
+	if d := l.reserve(); d <= 0 {
+		t.Errorf("Expected limiter to be paused after a 429 response. Got wait: %v\n", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 29, 17, 36, 20, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Time
+		expectOK bool
+	}{
+		{"5", now.Add(5 * time.Second), true},
+		{"Wed, 29 Jan 2020 17:37:00 GMT", time.Date(2020, 1, 29, 17, 37, 0, 0, time.UTC), true},
+		{"", time.Time{}, false},
+		{"soon", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		actual, ok := parseRetryAfter(test.value, now)
+		if ok != test.expectOK || !actual.Equal(test.expected) {
+			t.Errorf("Expected %v (%t) for Retry-After %q. Got: %v (%t)\n", test.expected, test.expectOK, test.value, actual, ok)
+		}
+	}
+}
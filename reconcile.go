@@ -0,0 +1,252 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpectedPayment is a payment recorded locally (e.g. in the orders table of a store), to be reconciled with the DERO Merchant server.
+type ExpectedPayment struct {
+	OrderID   string
+	PaymentID string
+	Amount    float64
+	Currency  string
+	Fulfilled bool // Whether the order was fulfilled (e.g. shipped) locally.
+}
+
+// ReconciliationIssue is the kind of discrepancy found by a Reconciler.
+type ReconciliationIssue string
+
+// Issues found by a Reconciler.
+const (
+	IssuePaidUnfulfilled ReconciliationIssue = "paid_unfulfilled" // Paid on the server but not fulfilled locally.
+	IssueFulfilledUnpaid ReconciliationIssue = "fulfilled_unpaid" // Fulfilled locally but not paid on the server.
+	IssueMissing         ReconciliationIssue = "missing"          // Recorded locally but not found on the server.
+	IssueAmountMismatch  ReconciliationIssue = "amount_mismatch"  // Amount or currency differ between local record and server.
+	IssueUnrecorded      ReconciliationIssue = "unrecorded"       // Created on the server but never recorded locally.
+)
+
+// ReconciliationEntry is a discrepancy found by a Reconciler.
+// Expected is nil for IssueUnrecorded, Payment is nil for IssueMissing.
+type ReconciliationEntry struct {
+	Issue    ReconciliationIssue
+	Expected *ExpectedPayment
+	Payment  *Payment
+}
+
+// ReconciliationReport is the result of a reconciliation.
+// Matched is the number of expected payments without any issue.
+type ReconciliationReport struct {
+	GeneratedAt time.Time
+	Matched     int
+	Entries     []ReconciliationEntry
+}
+
+// ByIssue returns the entries of the report with the given issue.
+func (r *ReconciliationReport) ByIssue(issue ReconciliationIssue) []ReconciliationEntry {
+	var entries []ReconciliationEntry
+	for _, e := range r.Entries {
+		if e.Issue == issue {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+var reconciliationCSVHeader = []string{
+	"issue", "order_id", "payment_id", "expected_amount", "expected_currency", "fulfilled",
+	"status", "currency", "currency_amount", "dero_amount", "creation_time",
+}
+
+// WriteCSV writes the entries of the report to w as CSV, with a header row.
+func (r *ReconciliationReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write(reconciliationCSVHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range r.Entries {
+		row := make([]string, len(reconciliationCSVHeader))
+		row[0] = string(e.Issue)
+
+		if e.Expected != nil {
+			row[1] = e.Expected.OrderID
+			row[2] = e.Expected.PaymentID
+			row[3] = strconv.FormatFloat(e.Expected.Amount, 'f', -1, 64)
+			row[4] = e.Expected.Currency
+			row[5] = strconv.FormatBool(e.Expected.Fulfilled)
+		}
+
+		if e.Payment != nil {
+			row[2] = e.Payment.PaymentID
+			row[6] = e.Payment.Status
+			row[7] = e.Payment.Currency
+			row[8] = strconv.FormatFloat(e.Payment.CurrencyAmount, 'f', -1, 64)
+			row[9] = e.Payment.DeroAmount
+			row[10] = e.Payment.CreationTime.UTC().Format(time.RFC3339)
+		}
+
+		err := cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReconcileOptions is a struct that holds the options of a reconciliation.
+// If Since is provided, the Payments created on the server between Since and Until (now, if not provided) are listed
+// with GetFilteredPayments to find the ones never recorded locally.
+// PageSize is the number of Payments per page of the listing. If not provided, it defaults to 100.
+// AmountTolerance is the maximum difference between expected and actual amount not reported as a mismatch. If not provided, it defaults to 1e-9.
+type ReconcileOptions struct {
+	Since           time.Time
+	Until           time.Time
+	PageSize        int
+	AmountTolerance float64
+	Batch           *BatchOptions
+}
+
+// Reconciler compares the payments recorded locally with the Payments on the DERO Merchant server.
+type Reconciler struct {
+	Client *Client
+}
+
+// NewReconciler returns a new Reconciler getting Payments with c.
+func NewReconciler(c *Client) *Reconciler {
+	return &Reconciler{Client: c}
+}
+
+// Reconcile compares expected with the Payments on the server and returns a report of the discrepancies. o is optional.
+func (r *Reconciler) Reconcile(ctx context.Context, expected []ExpectedPayment, o *ReconcileOptions) (*ReconciliationReport, error) {
+	if o == nil {
+		o = &ReconcileOptions{}
+	}
+	tolerance := o.AmountTolerance
+	if tolerance <= 0 {
+		tolerance = 1e-9
+	}
+
+	ids := make([]string, len(expected))
+	for i, e := range expected {
+		ids[i] = e.PaymentID
+	}
+
+	res, err := r.Client.GetPaymentsBatch(ctx, ids, o.Batch)
+	if err != nil {
+		return nil, err
+	}
+
+	payments := make(map[string]*Payment, len(res.Payments))
+	for _, p := range res.Payments {
+		payments[p.PaymentID] = p
+	}
+
+	report := &ReconciliationReport{
+		GeneratedAt: r.Client.now(),
+	}
+
+	recorded := make(map[string]bool, len(expected))
+	for i := range expected {
+		e := &expected[i]
+		recorded[e.PaymentID] = true
+
+		p, ok := payments[e.PaymentID]
+		if !ok {
+			report.Entries = append(report.Entries, ReconciliationEntry{Issue: IssueMissing, Expected: e})
+			continue
+		}
+
+		matched := true
+		if !strings.EqualFold(p.Currency, e.Currency) || math.Abs(p.CurrencyAmount-e.Amount) > tolerance {
+			report.Entries = append(report.Entries, ReconciliationEntry{Issue: IssueAmountMismatch, Expected: e, Payment: p})
+			matched = false
+		}
+
+		paid := p.Status == PaymentStatusPaid
+		if paid && !e.Fulfilled {
+			report.Entries = append(report.Entries, ReconciliationEntry{Issue: IssuePaidUnfulfilled, Expected: e, Payment: p})
+			matched = false
+		}
+		if !paid && e.Fulfilled {
+			report.Entries = append(report.Entries, ReconciliationEntry{Issue: IssueFulfilledUnpaid, Expected: e, Payment: p})
+			matched = false
+		}
+
+		if matched {
+			report.Matched++
+		}
+	}
+
+	if !o.Since.IsZero() {
+		until := o.Until
+		if until.IsZero() {
+			until = report.GeneratedAt
+		}
+
+		err := r.Client.eachFilteredPayment(ctx, o.PageSize, "", "", func(p *Payment) (bool, error) {
+			if p.CreationTime.Before(o.Since) {
+				return false, nil // Payments are listed from newest to oldest
+			}
+
+			if p.CreationTime.Before(until) && !recorded[p.PaymentID] {
+				report.Entries = append(report.Entries, ReconciliationEntry{Issue: IssueUnrecorded, Payment: p})
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// eachFilteredPayment calls fn for every Payment returned by GetFilteredPayments, from newest to oldest, until fn returns false or an error.
+// If pageSize is less than 1, it defaults to 100.
+// Payments created while paging shift the following pages, so the Payments already seen are skipped rather than passed to fn twice.
+func (c *Client) eachFilteredPayment(ctx context.Context, pageSize int, statusFilter, currencyFilter string, fn func(p *Payment) (bool, error)) error {
+	if pageSize < 1 {
+		pageSize = 100
+	}
+
+	seen := make(map[string]bool)
+	for page := 1; ; page++ {
+		resp, err := c.GetFilteredPaymentsWithContext(ctx, pageSize, page, "creation_time", "desc", statusFilter, currencyFilter)
+		if err != nil {
+			return err
+		}
+		if resp == nil {
+			return nil // Null response
+		}
+
+		for _, p := range resp.Payments {
+			if p == nil || seen[p.PaymentID] {
+				continue
+			}
+			seen[p.PaymentID] = true
+
+			more, err := fn(p)
+			if err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+		}
+
+		if page >= resp.TotalPages || len(resp.Payments) == 0 {
+			return nil
+		}
+	}
+}
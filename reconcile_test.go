@@ -0,0 +1,237 @@
+package deromerchant
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestPaymentsServer returns a fake server answering POST /payments and GET /payments (sorted by creation time, newest first) with payments.
+func newTestPaymentsServer(t *testing.T, payments []*Payment) *httptest.Server {
+	sorted := append([]*Payment(nil), payments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreationTime.After(sorted[j].CreationTime) })
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+
+		switch r.Method {
+		case http.MethodPost:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []string
+			err = json.Unmarshal(body, &ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found []*Payment
+			for _, id := range ids {
+				for _, p := range payments {
+					if p.PaymentID == id {
+						found = append(found, p)
+					}
+				}
+			}
+			resp = found
+		case http.MethodGet:
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			status := r.URL.Query().Get("status")
+
+			var filtered []*Payment
+			for _, p := range sorted {
+				if status == "" || p.Status == status {
+					filtered = append(filtered, p)
+				}
+			}
+
+			start, end := (page-1)*limit, page*limit
+			if start > len(filtered) {
+				start = len(filtered)
+			}
+			if end > len(filtered) {
+				end = len(filtered)
+			}
+
+			resp = &GetFilteredPaymentsResponse{
+				Limit:         limit,
+				Page:          page,
+				TotalPayments: len(filtered),
+				TotalPages:    (len(filtered) + limit - 1) / limit,
+				Payments:      filtered[start:end],
+			}
+		}
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	}))
+}
+
+func TestReconciler(t *testing.T) {
+	now := time.Now()
+
+	payments := []*Payment{
+		{PaymentID: "ok", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 10, CreationTime: now.Add(-1 * time.Hour)},
+		{PaymentID: "unfulfilled", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 20, CreationTime: now.Add(-2 * time.Hour)},
+		{PaymentID: "unpaid", Status: PaymentStatusExpired, Currency: "EUR", CurrencyAmount: 5, CreationTime: now.Add(-3 * time.Hour)},
+		{PaymentID: "mismatch", Status: PaymentStatusPending, Currency: "DERO", CurrencyAmount: 1.5, CreationTime: now.Add(-4 * time.Hour)},
+		{PaymentID: "unrecorded", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 99, CreationTime: now.Add(-5 * time.Hour)},
+		{PaymentID: "too-old", Status: PaymentStatusPaid, Currency: "USD", CurrencyAmount: 1, CreationTime: now.Add(-48 * time.Hour)},
+	}
+
+	ts := newTestPaymentsServer(t, payments)
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	expected := []ExpectedPayment{
+		{OrderID: "1", PaymentID: "ok", Amount: 10, Currency: "USD", Fulfilled: true},
+		{OrderID: "2", PaymentID: "unfulfilled", Amount: 20, Currency: "USD"},
+		{OrderID: "3", PaymentID: "unpaid", Amount: 5, Currency: "EUR", Fulfilled: true},
+		{OrderID: "4", PaymentID: "mismatch", Amount: 2, Currency: "DERO"},
+		{OrderID: "5", PaymentID: "missing", Amount: 1, Currency: "USD"},
+	}
+
+	report, err := NewReconciler(c).Reconcile(context.Background(), expected, &ReconcileOptions{
+		Since:    now.Add(-24 * time.Hour),
+		PageSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Matched != 1 {
+		t.Errorf("Expected 1 matched payment. Got: %d\n", report.Matched)
+	}
+
+	tests := []struct {
+		issue      ReconciliationIssue
+		paymentIDs []string
+	}{
+		{IssuePaidUnfulfilled, []string{"unfulfilled"}},
+		{IssueFulfilledUnpaid, []string{"unpaid"}},
+		{IssueAmountMismatch, []string{"mismatch"}},
+		{IssueMissing, []string{"missing"}},
+		{IssueUnrecorded, []string{"unrecorded"}},
+	}
+
+	for _, test := range tests {
+		entries := report.ByIssue(test.issue)
+		if len(entries) != len(test.paymentIDs) {
+			t.Errorf("Expected %d entries with issue %s. Got: %d\n", len(test.paymentIDs), test.issue, len(entries))
+			continue
+		}
+
+		for i, e := range entries {
+			id := ""
+			if e.Expected != nil {
+				id = e.Expected.PaymentID
+			} else {
+				id = e.Payment.PaymentID
+			}
+			if id != test.paymentIDs[i] {
+				t.Errorf("Expected Payment ID: %s with issue %s. Got: %s\n", test.paymentIDs[i], test.issue, id)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err = report.WriteCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(report.Entries)+1 {
+		t.Errorf("Expected %d CSV rows. Got: %d\n", len(report.Entries)+1, len(rows))
+	}
+	if rows[0][0] != "issue" {
+		t.Errorf("Expected CSV header. Got: %v\n", rows[0])
+	}
+}
+
+func TestEachFilteredPaymentShiftingPages(t *testing.T) {
+	now := time.Now()
+	payments := []*Payment{
+		{PaymentID: "c", CreationTime: now.Add(-1 * time.Hour)},
+		{PaymentID: "b", CreationTime: now.Add(-2 * time.Hour)},
+		{PaymentID: "a", CreationTime: now.Add(-3 * time.Hour)},
+	}
+
+	null := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if null {
+			w.Write([]byte("null"))
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		resp := &GetFilteredPaymentsResponse{Limit: 2, Page: page, TotalPayments: len(payments), TotalPages: (len(payments) + 1) / 2}
+		if start := (page - 1) * 2; start < len(payments) {
+			end := start + 2
+			if end > len(payments) {
+				end = len(payments)
+			}
+			resp.Payments = payments[start:end]
+		}
+
+		// A Payment created after the first page shifts the following ones
+		if page == 1 {
+			payments = append([]*Payment{{PaymentID: "d", CreationTime: now}}, payments...)
+		}
+
+		b, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	var ids []string
+	err = c.eachFilteredPayment(context.Background(), 2, "", "", func(p *Payment) (bool, error) {
+		ids = append(ids, p.PaymentID)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[c b a]" {
+		t.Errorf("Expected each Payment once: [c b a]. Got: %v\n", ids)
+	}
+
+	// Null responses end the listing
+	null = true
+	n, err := c.ExportPayments(context.Background(), ioutil.Discard, nil)
+	if err != nil || n != 0 {
+		t.Errorf("Expected no Payment exported and no error. Got: %d, %v\n", n, err)
+	}
+}
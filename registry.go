@@ -0,0 +1,291 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownStore is returned by StoreRegistry when no store with the requested ID is registered,
+// or when no registered store's Webhook Secret Key matches a webhook request.
+var ErrUnknownStore = errors.New("DeroMerchant: unknown store")
+
+// StoreConfig holds the keys of a store registered in a StoreRegistry.
+type StoreConfig struct {
+	ID               string
+	APIKey           string
+	SecretKey        string
+	WebhookSecretKey string
+}
+
+// Store is a store registered in a StoreRegistry, with its own Client and Webhook Secret Keys.
+// The Webhook Secret Key of StoreConfig is the first key of WebhookKeys, with the store ID as key ID.
+// More keys can be added to WebhookKeys during a rotation.
+type Store struct {
+	ID          string
+	Client      *Client
+	WebhookKeys *WebhookKeySet
+
+	config StoreConfig
+}
+
+// StoreSource is a pluggable source (e.g. a database) of the stores of a StoreRegistry.
+type StoreSource interface {
+	Stores(ctx context.Context) ([]StoreConfig, error)
+}
+
+// StoreRegistry holds the Clients of multiple stores, e.g. on a marketplace platform handling payments on behalf of many merchants.
+// All Clients share the same http.Client (and therefore the same connection pool).
+// StoreRegistry is safe for concurrent use.
+type StoreRegistry struct {
+	httpClient *http.Client
+	opts       []Option
+
+	mu     sync.RWMutex
+	stores map[string]*Store
+}
+
+// NewStoreRegistry returns a new empty StoreRegistry.
+// Options are applied to the Client of every store, after the shared http.Client.
+func NewStoreRegistry(opts ...Option) *StoreRegistry {
+	return &StoreRegistry{
+		httpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+		opts:   opts,
+		stores: make(map[string]*Store),
+	}
+}
+
+func (r *StoreRegistry) newStore(cfg StoreConfig) (*Store, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("DeroMerchant: store has no ID")
+	}
+
+	opts := append([]Option{WithHTTPClient(r.httpClient)}, r.opts...)
+	c, err := NewClient(&ClientOptions{
+		APIKey:    cfg.APIKey,
+		SecretKey: cfg.SecretKey,
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookKey, err := ParseSecretKey(cfg.WebhookSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := NewWebhookKeySetWithClock(c.clockOrSystem(), WebhookKey{ID: cfg.ID, Key: webhookKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		ID:          cfg.ID,
+		Client:      c,
+		WebhookKeys: keys,
+		config:      cfg,
+	}, nil
+}
+
+// Add registers the store described by cfg, replacing any store with the same ID.
+func (r *StoreRegistry) Add(cfg StoreConfig) (*Store, error) {
+	s, err := r.newStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.stores[s.ID] = s
+	r.mu.Unlock()
+
+	return s, nil
+}
+
+// Remove unregisters the store with ID id. It returns whether the store was registered.
+func (r *StoreRegistry) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.stores[id]
+	delete(r.stores, id)
+	return ok
+}
+
+// Store returns the store with ID id.
+func (r *StoreRegistry) Store(id string) (*Store, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.stores[id]
+	if !ok {
+		return nil, ErrUnknownStore
+	}
+
+	return s, nil
+}
+
+// Client returns the Client of the store with ID id.
+func (r *StoreRegistry) Client(id string) (*Client, error) {
+	s, err := r.Store(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Client, nil
+}
+
+// Stores returns the registered stores, sorted by ID.
+func (r *StoreRegistry) Stores() []*Store {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stores := make([]*Store, 0, len(r.stores))
+	for _, s := range r.stores {
+		stores = append(stores, s)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].ID < stores[j].ID })
+
+	return stores
+}
+
+// Load synchronizes the registry with the stores of src: new stores are added, stores whose keys changed are replaced
+// and stores missing from src are removed. Unchanged stores keep their Client and Webhook Secret Keys.
+// Replaced stores keep their WebhookKeySet, with keys added during a rotation. If the Webhook Secret Key changed,
+// the new key is inserted first with the store ID as key ID, and the previous one is kept with ID suffixed by
+// PreviousWebhookKeySuffix for WebhookKeyGracePeriod.
+// If any store of src is invalid, the registry is left untouched.
+func (r *StoreRegistry) Load(ctx context.Context, src StoreSource) error {
+	configs, err := src.Stores(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type rotation struct {
+		keys *WebhookKeySet
+		key  WebhookKey
+	}
+	var rotations []rotation
+
+	stores := make(map[string]*Store, len(configs))
+	for _, cfg := range configs {
+		current, ok := r.stores[cfg.ID]
+		if ok && current.config == cfg {
+			stores[cfg.ID] = current
+			continue
+		}
+
+		s, err := r.newStore(cfg)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if cfg.WebhookSecretKey != current.config.WebhookSecretKey {
+				rotations = append(rotations, rotation{current.WebhookKeys, s.WebhookKeys.Keys()[0]})
+			}
+			s.WebhookKeys = current.WebhookKeys
+		}
+		stores[cfg.ID] = s
+	}
+
+	// Key sets are only modified once every store is known to be valid
+	for _, rot := range rotations {
+		rot.keys.rotate(rot.key, WebhookKeyGracePeriod)
+	}
+	r.stores = stores
+
+	return nil
+}
+
+// VerifyWebhook verifies and parses a webhook request sent to the store with ID storeID (e.g. taken from a path parameter of the webhook URL).
+// The request is decoded with the DecodeOptions of the Client of the store (see WithDecodeOptions), if any.
+// Function can return defined errors ErrUnknownStore, ErrNoWebhookSignature or ErrInvalidSignature.
+func (r *StoreRegistry) VerifyWebhook(req *http.Request, storeID string) (*Store, *PaymentUpdateEvent, error) {
+	s, err := r.Store(storeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, e, err := s.WebhookKeys.VerifyAndParseWithOptions(req, s.Client.decoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, e, nil
+}
+
+// IdentifyWebhook verifies and parses a webhook request by trying the Webhook Secret Keys of every registered store.
+// It returns the store whose key matched, and decodes the request with the DecodeOptions of its Client, if any. Prefer VerifyWebhook when the webhook URL identifies the store, as its cost does not grow with the number of stores.
+// Function can return defined errors ErrNoWebhookSignature or ErrUnknownStore (if no store's key matched).
+func (r *StoreRegistry) IdentifyWebhook(req *http.Request) (*Store, *PaymentUpdateEvent, error) {
+	signature, body, err := readWebhookSignature(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range r.Stores() {
+		_, err := s.WebhookKeys.verifyMAC(body, signature)
+		if err == ErrInvalidSignature {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e, err := ParseWebhookRequestWithOptions(req, s.Client.decoding)
+		if err != nil {
+			return s, nil, err
+		}
+
+		return s, e, nil
+	}
+
+	return nil, nil, ErrUnknownStore
+}
+
+// WebhookHandlerFunc handles a verified webhook request sent to store s.
+type WebhookHandlerFunc func(w http.ResponseWriter, r *http.Request, s *Store, e *PaymentUpdateEvent)
+
+// WebhookHandler returns an http.Handler that routes webhook requests to the right store and calls h with the verified event.
+// storeID returns the ID of the store a request was sent to (e.g. from a path parameter). If storeID is nil, or returns an empty string,
+// the store is identified by trying the keys of every store.
+// Requests that fail verification are answered with 401 Unauthorized, requests to unknown stores with 404 Not Found.
+// Requests are decoded with the DecodeOptions passed to NewStoreRegistry with WithDecodeOptions, if any.
+func (r *StoreRegistry) WebhookHandler(storeID func(req *http.Request) string, h WebhookHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var (
+			s   *Store
+			e   *PaymentUpdateEvent
+			err error
+		)
+
+		id := ""
+		if storeID != nil {
+			id = storeID(req)
+		}
+
+		if id != "" {
+			s, e, err = r.VerifyWebhook(req, id)
+		} else {
+			s, e, err = r.IdentifyWebhook(req)
+		}
+
+		switch {
+		case err == ErrUnknownStore && id != "":
+			http.Error(w, "Unknown store", http.StatusNotFound)
+		case err == ErrUnknownStore || err == ErrInvalidSignature || err == ErrNoWebhookSignature:
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		case err != nil:
+			http.Error(w, "Bad request", http.StatusBadRequest)
+		default:
+			h(w, req, s, e)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testStoreSource []StoreConfig
+
+func (s testStoreSource) Stores(ctx context.Context) ([]StoreConfig, error) {
+	return s, nil
+}
+
+func TestStoreRegistry(t *testing.T) {
+	const (
+		webhookSecretKeyA = "010f2b45384c57bd388bccb520722abd8d5a61f66ca71fcd25bf7942d067ca73"
+		webhookSecretKeyB = "1e9a0eefcff11530a1bc247672e9ebcb712fc6ab82e0b54b0e586c8adc33b0c0"
+	)
+
+	storeA := StoreConfig{ID: "a", APIKey: validAPIKey, SecretKey: validSecretKey, WebhookSecretKey: webhookSecretKeyA}
+	storeB := StoreConfig{ID: "b", APIKey: invalidAPIKey, SecretKey: invalidSecretKey, WebhookSecretKey: webhookSecretKeyB}
+
+	r := NewStoreRegistry()
+	err := r.Load(context.Background(), testStoreSource{storeA, storeB})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := r.Store("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Store("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Client.HTTPClient != b.Client.HTTPClient {
+		t.Error("Expected stores to share the same HTTP client")
+	}
+	if a.Client.apiKey != validAPIKey || b.Client.apiKey != invalidAPIKey {
+		t.Error("Expected each store to have its own API key")
+	}
+
+	// Webhooks routed by store ID and by trying candidate keys
+	var (
+		handledStore *Store
+		handledEvent *PaymentUpdateEvent
+	)
+	h := r.WebhookHandler(func(req *http.Request) string {
+		return strings.TrimPrefix(req.URL.Path, "/webhook/")
+	}, func(w http.ResponseWriter, req *http.Request, s *Store, e *PaymentUpdateEvent) {
+		handledStore, handledEvent = s, e
+	})
+
+	e := &PaymentUpdateEvent{PaymentID: "6c8dd967897d8c46879d75236027f4791816146bed38a259a1dbdb8e047c10b4", Status: "paid"}
+
+	tests := []struct {
+		path            string
+		key             string
+		expectedStatus  int
+		expectedStoreID string
+	}{
+		{"/webhook/a", webhookSecretKeyA, http.StatusOK, "a"},
+		{"/webhook/b", webhookSecretKeyB, http.StatusOK, "b"},
+		{"/webhook/", webhookSecretKeyB, http.StatusOK, "b"},
+		{"/webhook/a", webhookSecretKeyB, http.StatusUnauthorized, ""},
+		{"/webhook/c", webhookSecretKeyA, http.StatusNotFound, ""},
+		{"/webhook/", validSecretKey, http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		handledStore, handledEvent = nil, nil
+
+		req, err := createWebhookRequest("http://localhost"+test.path, e, test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != test.expectedStatus {
+			t.Errorf("Expected status %d for %s. Got: %d\n", test.expectedStatus, test.path, w.Code)
+		}
+
+		if test.expectedStoreID == "" {
+			if handledStore != nil {
+				t.Errorf("Expected webhook for %s not to be handled. Got store: %s\n", test.path, handledStore.ID)
+			}
+			continue
+		}
+
+		if handledStore == nil || handledStore.ID != test.expectedStoreID || !reflect.DeepEqual(handledEvent, e) {
+			t.Errorf("Expected webhook for %s to be handled by store %s\n", test.path, test.expectedStoreID)
+		}
+	}
+
+	// Key added to store a during a rotation
+	err = a.WebhookKeys.Add(WebhookKey{ID: "rotated", Key: mustParseSecretKey(t, webhookSecretKeyB)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged stores are kept, changed stores replaced, missing stores removed
+	storeA2 := storeA
+	storeA2.APIKey = invalidAPIKey
+	err = r.Load(context.Background(), testStoreSource{storeA2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Store("b"); err != ErrUnknownStore {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrUnknownStore, err)
+	}
+
+	c, err := r.Client("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == a.Client || c.apiKey != invalidAPIKey {
+		t.Error("Expected store a to be replaced")
+	}
+
+	// Replaced stores keep their rotated keys, and the previous key of the store ID is accepted during a grace period
+	storeA2.WebhookSecretKey = webhookSecretKeyB
+	err = r.Load(context.Background(), testStoreSource{storeA2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := r.Store("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := a2.WebhookKeys.Keys()
+	if len(keys) != 3 || keys[0].ID != "a" || !keys[0].Key.Equal(mustParseSecretKey(t, webhookSecretKeyB)) ||
+		keys[1].ID != "a"+PreviousWebhookKeySuffix || !keys[1].Key.Equal(mustParseSecretKey(t, webhookSecretKeyA)) || keys[2].ID != "rotated" {
+		t.Errorf("Expected keys a (new), a.previous (old) and rotated. Got: %+v\n", keys)
+	}
+	if grace := time.Until(keys[1].NotAfter); grace <= 0 || grace > WebhookKeyGracePeriod {
+		t.Errorf("Expected previous key to expire within %v. Got: %v\n", WebhookKeyGracePeriod, keys[1].NotAfter)
+	}
+
+	for _, key := range []string{webhookSecretKeyA, webhookSecretKeyB} {
+		req, err := createWebhookRequest("http://localhost/webhook/a", e, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected webhook signed with old and new keys to be accepted. Got status: %d\n", w.Code)
+		}
+	}
+
+	// Rotating again replaces the previous key
+	storeA2.WebhookSecretKey = webhookSecretKeyA
+	err = r.Load(context.Background(), testStoreSource{storeA2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys = a2.WebhookKeys.Keys()
+	if len(keys) != 3 || !keys[0].Key.Equal(mustParseSecretKey(t, webhookSecretKeyA)) || !keys[1].Key.Equal(mustParseSecretKey(t, webhookSecretKeyB)) {
+		t.Errorf("Expected keys a (new) and a.previous (old). Got: %+v\n", keys)
+	}
+
+	// Stores with an empty Webhook Secret Key are rejected, so webhooks signed with an empty key can not be forged
+	_, err = r.Add(StoreConfig{ID: "empty", APIKey: validAPIKey, SecretKey: validSecretKey})
+	if !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSecretKey, err)
+	}
+
+	forged, err := createWebhookRequest("http://localhost/webhook/", e, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, forged)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected webhook signed with an empty key to be refused. Got status: %d\n", w.Code)
+	}
+
+	// Invalid stores leave the registry untouched
+	err = r.Load(context.Background(), testStoreSource{storeA, {ID: "bad", SecretKey: "not hex"}})
+	if err == nil {
+		t.Error("Expected error")
+	}
+	if len(r.Stores()) != 1 {
+		t.Errorf("Expected 1 store. Got: %d\n", len(r.Stores()))
+	}
+}
@@ -0,0 +1,128 @@
+package deromerchant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response holds the metadata of a response of the API: status code, headers, raw body, request ID and time elapsed.
+// Pass a Response to ContextWithResponse, or use the WithResponse variants of the methods of Client, to get the Response of a call.
+type Response struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	RequestID  string // Value of the X-Request-Id header. Support requests to DERO Merchant should include it.
+	Elapsed    time.Duration
+}
+
+type responseContextKey struct{}
+
+// responseSink is the Response of a context, written under a lock as the requests of a call may be sent concurrently.
+type responseSink struct {
+	mu   sync.Mutex
+	resp *Response
+}
+
+func (s *responseSink) store(r *Response) {
+	s.mu.Lock()
+	*s.resp = *r
+	s.mu.Unlock()
+}
+
+// ContextWithResponse returns a copy of ctx that makes the Client fill resp with the metadata of the response received.
+// If a call sends several requests (e.g. GetPaymentsBatch), possibly concurrently, resp holds the last response received.
+// resp must not be read before the call returns.
+// Calls with a Response in their context are not coalesced with concurrent identical calls.
+func ContextWithResponse(ctx context.Context, resp *Response) context.Context {
+	return context.WithValue(ctx, responseContextKey{}, &responseSink{resp: resp})
+}
+
+func responseFromContext(ctx context.Context) *responseSink {
+	sink, _ := ctx.Value(responseContextKey{}).(*responseSink)
+	return sink
+}
+
+// WithResponseHook makes the Client call hook with the metadata of every response received (e.g. to log request IDs).
+func WithResponseHook(hook func(resp *Response)) Option {
+	return func(c *Client) error {
+		if hook == nil {
+			return errors.New("DeroMerchant Client: nil response hook")
+		}
+
+		c.responseHook = hook
+		return nil
+	}
+}
+
+// observeResponse reports the metadata of resp to the Response of the context of req and to the response hook, if any.
+func (c *Client) observeResponse(req *http.Request, resp *http.Response, body []byte, elapsed time.Duration) {
+	dst := responseFromContext(req.Context())
+	if dst == nil && c.responseHook == nil {
+		return
+	}
+
+	r := &Response{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Elapsed:    elapsed,
+	}
+
+	if dst != nil {
+		dst.store(r)
+	}
+	if c.responseHook != nil {
+		c.responseHook(r)
+	}
+}
+
+// withResponse returns resp, or nil if no response was received.
+func withResponse(resp *Response) *Response {
+	if resp.StatusCode == 0 {
+		return nil
+	}
+	return resp
+}
+
+// PingWithResponse is like PingWithContext but also returns the metadata of the response. The Response is nil if none was received.
+func (c *Client) PingWithResponse(ctx context.Context) (*PingResponse, *Response, error) {
+	resp := &Response{}
+	v, err := c.PingWithContext(ContextWithResponse(ctx, resp))
+	return v, withResponse(resp), err
+}
+
+// CreatePaymentWithResponse is like CreatePaymentWithContext but also returns the metadata of the response. The Response is nil if none was received.
+func (c *Client) CreatePaymentWithResponse(ctx context.Context, currency string, amount float64) (*Payment, *Response, error) {
+	resp := &Response{}
+	v, err := c.CreatePaymentWithContext(ContextWithResponse(ctx, resp), currency, amount)
+	return v, withResponse(resp), err
+}
+
+// GetPaymentWithResponse is like GetPaymentWithContext but also returns the metadata of the response. The Response is nil if none was received.
+func (c *Client) GetPaymentWithResponse(ctx context.Context, paymentID string) (*Payment, *Response, error) {
+	resp := &Response{}
+	v, err := c.GetPaymentWithContext(ContextWithResponse(ctx, resp), paymentID)
+	return v, withResponse(resp), err
+}
+
+// GetPaymentsWithResponse is like GetPaymentsWithContext but also returns the metadata of the (last) response. The Response is nil if none was received.
+func (c *Client) GetPaymentsWithResponse(ctx context.Context, paymentIDs []string) ([]*Payment, *Response, error) {
+	resp := &Response{}
+	v, err := c.GetPaymentsWithContext(ContextWithResponse(ctx, resp), paymentIDs)
+	return v, withResponse(resp), err
+}
+
+// GetFilteredPaymentsWithResponse is like GetFilteredPaymentsWithContext but also returns the metadata of the response. The Response is nil if none was received.
+func (c *Client) GetFilteredPaymentsWithResponse(ctx context.Context, limit, page int, sortBy, orderBy, statusFilter, currencyFilter string) (*GetFilteredPaymentsResponse, *Response, error) {
+	resp := &Response{}
+	v, err := c.GetFilteredPaymentsWithContext(ContextWithResponse(ctx, resp), limit, page, sortBy, orderBy, statusFilter, currencyFilter)
+	return v, withResponse(resp), err
+}
@@ -0,0 +1,133 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-"+r.URL.Path)
+		w.Header().Set("X-RateLimit-Remaining", "9")
+
+		if r.URL.Path == "/payment/missing" {
+			err := sendErrorResponse(w, http.StatusNotFound, "Payment Not Found")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		w.Write([]byte(`{"paymentID": "a", "unknown": true}`))
+	}))
+	defer ts.Close()
+
+	var hooked []*Response
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, WithBaseURL(ts.URL), WithResponseHook(func(resp *Response) {
+		hooked = append(hooked, resp)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, resp, err := c.GetPaymentWithResponse(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentID != "a" {
+		t.Errorf("Expected Payment ID: a. Got: %s\n", p.PaymentID)
+	}
+	if resp.StatusCode != http.StatusOK || resp.RequestID != "req-/payment/a" || resp.Header.Get("X-RateLimit-Remaining") != "9" ||
+		string(resp.Body) != `{"paymentID": "a", "unknown": true}` || resp.Method != http.MethodGet || resp.URL != ts.URL+"/payment/a" {
+		t.Errorf("Unexpected response: %+v\n", resp)
+	}
+	if resp.Elapsed <= 0 {
+		t.Errorf("Expected elapsed time. Got: %v\n", resp.Elapsed)
+	}
+
+	// Response is filled on errors too
+	_, resp, err = c.GetPaymentWithResponse(context.Background(), "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found error. Got: %v\n", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound || resp.RequestID != "req-/payment/missing" {
+		t.Errorf("Unexpected response: %+v\n", resp)
+	}
+
+	// No response received
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, resp, err = c.PingWithResponse(ctx)
+	if err == nil || resp != nil {
+		t.Errorf("Expected error and no response. Got: %v and %+v\n", err, resp)
+	}
+
+	if len(hooked) != 2 || hooked[1].StatusCode != http.StatusNotFound {
+		t.Errorf("Expected hook to be called for 2 responses. Got: %d\n", len(hooked))
+	}
+}
+
+func TestResponseConcurrentBatches(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ids []string
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		payments := make([]*Payment, len(ids))
+		for i, id := range ids {
+			payments[i] = &Payment{PaymentID: id}
+		}
+		json.NewEncoder(w).Encode(payments)
+	}))
+	defer ts.Close()
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%064d", i)
+	}
+
+	// Each Call gets the Response of its own request, even when batches share the Response of the context
+	checkCall := Interceptor(func(next Doer) Doer {
+		return DoerFunc(func(call *Call) error {
+			body, err := readBody(call.Request)
+			if err != nil {
+				return err
+			}
+
+			err = next.Do(call)
+			if err == nil && (call.Response == nil || !strings.Contains(string(call.Response.Body), string(body[2:66]))) {
+				t.Error("Expected Response of the request sent by the Call")
+			}
+			return err
+		})
+	})
+
+	for _, opts := range [][]Option{nil, {WithInterceptors(checkCall)}} {
+		c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+		var resp Response
+		res, err := c.GetPaymentsBatch(ContextWithResponse(context.Background(), &resp), ids, &BatchOptions{BatchSize: 10, Concurrency: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Payments) != len(ids) {
+			t.Errorf("Expected %d payments. Got: %d\n", len(ids), len(res.Payments))
+		}
+		if resp.StatusCode != http.StatusOK || resp.Method != http.MethodPost {
+			t.Errorf("Expected Response of the last request. Got: %+v\n", resp)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package deromerchant
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrInvalidSecretKey is returned by ParseSecretKey if the key is not hex encoded or is shorter than MinSecretKeyLength.
+	// The key itself is never part of the error.
+	ErrInvalidSecretKey = errors.New("DeroMerchant: invalid secret key")
+	// ErrSecretKeyZeroed is returned when signing or verifying with a SecretKey whose memory was zeroed with Zero.
+	ErrSecretKeyZeroed = errors.New("DeroMerchant: secret key was zeroed")
+)
+
+const redactedSecretKey = "[REDACTED]"
+
+// MinSecretKeyLength is the minimum length in bytes of a decoded Secret Key. Keys of DERO Merchant stores are 32 bytes long.
+// Shorter keys (in particular empty ones, with which anyone can forge a signature) are rejected.
+const MinSecretKeyLength = 16
+
+// SecretKey holds a decoded Secret Key (or Webhook Secret Key) of a store.
+// It is parsed and checked once with ParseSecretKey, then reused by request signing and webhook verification.
+// Its value is redacted when formatted or marshalled, so it never ends up in logs or panic dumps.
+// Use Zero to wipe its memory when the key is not needed anymore.
+type SecretKey struct {
+	mu     sync.RWMutex
+	key    []byte
+	zeroed bool
+}
+
+// ParseSecretKey returns the SecretKey encoded in hex string s.
+// Function can return an error matching ErrInvalidSecretKey if s is not hex encoded or is shorter than MinSecretKeyLength bytes.
+func ParseSecretKey(s string) (*SecretKey, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not hex encoded", ErrInvalidSecretKey)
+	}
+	if len(key) < MinSecretKeyLength {
+		return nil, fmt.Errorf("%w: shorter than %d bytes", ErrInvalidSecretKey, MinSecretKeyLength)
+	}
+
+	return &SecretKey{key: key}, nil
+}
+
+// NewSecretKey returns a SecretKey holding a copy of key.
+// Signing and verifying with a key shorter than MinSecretKeyLength fail with ErrInvalidSecretKey.
+func NewSecretKey(key []byte) *SecretKey {
+	return &SecretKey{key: append([]byte(nil), key...)}
+}
+
+// String returns a redacted representation of the key.
+func (k *SecretKey) String() string {
+	return redactedSecretKey
+}
+
+// GoString returns a redacted representation of the key. It is used by the %#v verb.
+func (k *SecretKey) GoString() string {
+	return redactedSecretKey
+}
+
+// MarshalJSON marshals the key as a redacted string.
+func (k *SecretKey) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redactedSecretKey + `"`), nil
+}
+
+// MarshalText marshals the key as a redacted string.
+func (k *SecretKey) MarshalText() ([]byte, error) {
+	return []byte(redactedSecretKey), nil
+}
+
+// Zero overwrites the memory of the key. A zeroed key can not be used anymore: signing and verification return ErrSecretKeyZeroed.
+func (k *SecretKey) Zero() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	k.key = nil
+	k.zeroed = true
+}
+
+// Equal returns whether k and other hold the same key, in constant time.
+func (k *SecretKey) Equal(other *SecretKey) bool {
+	if k == nil || other == nil {
+		return k == other
+	}
+	if k == other {
+		return true
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+
+	return subtle.ConstantTimeCompare(k.key, other.key) == 1
+}
+
+// tooShort returns whether the key is shorter than MinSecretKeyLength. Zeroed keys are not too short.
+func (k *SecretKey) tooShort() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return !k.zeroed && len(k.key) < MinSecretKeyLength
+}
+
+// sign returns the MAC of msg generated with the key.
+func (k *SecretKey) sign(msg []byte) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.zeroed {
+		return nil, ErrSecretKeyZeroed
+	}
+	if len(k.key) < MinSecretKeyLength {
+		return nil, ErrInvalidSecretKey
+	}
+
+	return signMessage(msg, k.key)
+}
+
+// validMAC returns whether messageMAC is the MAC of message generated with the key.
+func (k *SecretKey) validMAC(message, messageMAC []byte) (bool, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.zeroed {
+		return false, ErrSecretKeyZeroed
+	}
+	if len(k.key) < MinSecretKeyLength {
+		return false, ErrInvalidSecretKey
+	}
+
+	return validMAC(message, messageMAC, k.key)
+}
@@ -0,0 +1,80 @@
+package deromerchant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretKeyRedaction(t *testing.T) {
+	k := mustParseSecretKey(t, secretKey)
+
+	type config struct {
+		APIKey    string
+		SecretKey *SecretKey
+	}
+	cfg := config{APIKey: apiKey, SecretKey: k}
+
+	formatted := []string{
+		fmt.Sprint(k),
+		fmt.Sprintf("%s %v %+v %#v %x", k, k, k, k, k),
+		fmt.Sprintf("%v %+v %#v", cfg, cfg, cfg),
+	}
+
+	b, err := json.Marshal(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted = append(formatted, string(b))
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted = append(formatted, fmt.Sprintf("%v %+v %#v", c, *c, c))
+
+	for _, f := range formatted {
+		if strings.Contains(f, secretKey) {
+			t.Errorf("Expected secret key to be redacted. Got: %s\n", f)
+		}
+	}
+
+	for _, s := range []string{"not hex " + secretKey, "", secretKey[:2*MinSecretKeyLength-2]} {
+		_, err = ParseSecretKey(s)
+		if !errors.Is(err, ErrInvalidSecretKey) || (s != "" && strings.Contains(err.Error(), s)) {
+			t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSecretKey, err)
+		}
+	}
+
+	_, err = NewSecretKey(nil).sign([]byte("message"))
+	if err != ErrInvalidSecretKey {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSecretKey, err)
+	}
+}
+
+func TestSecretKeyZero(t *testing.T) {
+	k := mustParseSecretKey(t, secretKey)
+	other := mustParseSecretKey(t, secretKey)
+
+	if !k.Equal(other) {
+		t.Error("Expected keys to be equal")
+	}
+
+	_, err := k.sign([]byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.Zero()
+
+	if k.Equal(other) {
+		t.Error("Expected zeroed key not to be equal to original key")
+	}
+
+	_, err = k.sign([]byte("message"))
+	if err != ErrSecretKeyZeroed {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrSecretKeyZeroed, err)
+	}
+}
@@ -0,0 +1,242 @@
+package deromerchant
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Versions of the scheme used to sign requests. Set it as the SignatureVersion field of ClientOptions.
+//
+// SignatureV1 (default) signs the body of the request only, and only requests sent with SendSignedRequest.
+//
+// SignatureV2 signs every request. The signature is a MAC of a canonical string made of method, path,
+// sorted query, timestamp, nonce and SHA-256 hash of the body, so a signed request cannot be replayed
+// against another endpoint or after its timestamp expires.
+// It is sent in its own headers, in addition to the SignatureV1 X-Signature header of requests with a body,
+// which the DERO Merchant server keeps checking.
+const (
+	SignatureV1 = 1
+	SignatureV2 = 2
+)
+
+// Headers of a request signed with SignatureV2.
+const (
+	SignatureV2Header        = "X-Signature-V2" // MAC of the canonical request.
+	SignatureVersionHeader   = "X-Signature-Version"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+)
+
+const signatureV2Prefix = "DM-HMAC-SHA256-V2"
+
+// DefaultSignatureMaxSkew is the maximum difference between the timestamp of a request signed with SignatureV2 and the time of its verification,
+// used by VerifyRequestSignature when maxSkew is 0.
+const DefaultSignatureMaxSkew = 5 * time.Minute
+
+var (
+	// ErrNoRequestSignature is returned by VerifyRequestSignature if the request lacks one of the SignatureV2 headers,
+	// and by VerifyRequestSignatureV1 if it lacks the X-Signature header.
+	ErrNoRequestSignature = errors.New("DeroMerchant: request has no signature headers")
+	// ErrUnsupportedSignatureVersion is returned by VerifyRequestSignature if the X-Signature-Version header is not 2.
+	ErrUnsupportedSignatureVersion = errors.New("DeroMerchant: request has unsupported signature version")
+	// ErrSignatureExpired is returned by VerifyRequestSignature if the X-Signature-Timestamp header is too far from the current time.
+	ErrSignatureExpired = errors.New("DeroMerchant: request signature expired")
+)
+
+// canonicalQuery returns the query of u with keys and values sorted.
+func canonicalQuery(u *url.URL) string {
+	q := u.Query()
+
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// canonicalRequest returns the string signed by SignatureV2.
+func canonicalRequest(method string, u *url.URL, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+
+	return []byte(strings.Join([]string{
+		signatureV2Prefix,
+		strings.ToUpper(method),
+		u.EscapedPath(),
+		canonicalQuery(u),
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n"))
+}
+
+// readBody reads the body of req and makes it readable again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		b, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer b.Close()
+
+		return ioutil.ReadAll(b)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // Make body readable again
+
+	return body, nil
+}
+
+func newNonce(random io.Reader) (string, error) {
+	b := make([]byte, 16)
+	_, err := io.ReadFull(random, b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// signRequestV2 sets the SignatureV2 headers of req, reading the nonce from random.
+func signRequestV2(req *http.Request, key *SecretKey, now time.Time, random io.Reader) error {
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := newNonce(random)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	s, err := key.sign(canonicalRequest(req.Method, req.URL, timestamp, nonce, body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(SignatureVersionHeader, strconv.Itoa(SignatureV2))
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonce)
+	req.Header.Set(SignatureV2Header, hex.EncodeToString(s))
+	return nil
+}
+
+// VerifyRequestSignature verifies the SignatureV2 headers of req with secretKey.
+// Requests whose timestamp is more than maxSkew away from the current time are rejected. If maxSkew is 0, DefaultSignatureMaxSkew is used.
+// Callers that need replay protection within maxSkew should also check the X-Signature-Nonce header was not seen before.
+// Function returns nil if the signature is valid. It can return defined errors ErrNoRequestSignature, ErrUnsupportedSignatureVersion,
+// ErrSignatureExpired or ErrInvalidSignature.
+func VerifyRequestSignature(req *http.Request, secretKey *SecretKey, maxSkew time.Duration) error {
+	return VerifyRequestSignatureAt(req, secretKey, maxSkew, time.Now())
+}
+
+// VerifyRequestSignatureAt is like VerifyRequestSignature but the timestamp of req is checked against now instead of the current time.
+func VerifyRequestSignatureAt(req *http.Request, secretKey *SecretKey, maxSkew time.Duration, now time.Time) error {
+	version := req.Header.Get(SignatureVersionHeader)
+	timestamp := req.Header.Get(SignatureTimestampHeader)
+	nonce := req.Header.Get(SignatureNonceHeader)
+	h := req.Header.Get(SignatureV2Header)
+	if version == "" || timestamp == "" || nonce == "" || h == "" {
+		return ErrNoRequestSignature
+	}
+
+	if version != strconv.Itoa(SignatureV2) {
+		return ErrUnsupportedSignatureVersion
+	}
+
+	if maxSkew == 0 {
+		maxSkew = DefaultSignatureMaxSkew
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("DeroMerchant: invalid signature timestamp: %w", err)
+	}
+	skew := now.Sub(time.Unix(ts, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return ErrSignatureExpired
+	}
+
+	signature, err := hex.DecodeString(h)
+	if err != nil {
+		return err
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+
+	valid, err := secretKey.validMAC(canonicalRequest(req.Method, req.URL, timestamp, nonce, body), signature)
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// VerifyRequestSignatureV1 verifies the SignatureV1 of req with secretKey: the MAC of the body sent in the X-Signature header.
+// SignatureV1 covers neither the method, path and query of req nor when it was sent, so prefer SignatureV2 when both sides support it.
+// Function returns nil if the signature is valid. It can return defined errors ErrNoRequestSignature or ErrInvalidSignature.
+func VerifyRequestSignatureV1(req *http.Request, secretKey *SecretKey) error {
+	h := req.Header.Get("X-Signature")
+	if h == "" {
+		return ErrNoRequestSignature
+	}
+
+	signature, err := hex.DecodeString(h)
+	if err != nil {
+		return err
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return err
+	}
+
+	valid, err := secretKey.validMAC(body, signature)
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package deromerchant
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	u, err := url.Parse("http://localhost:8080/api/v1/payments?status=paid&limit=10&currency=USD&currency=EUR&sort_by=creation_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "currency=EUR&currency=USD&limit=10&sort_by=creation_time&status=paid"
+	if actual := canonicalQuery(u); actual != expected {
+		t.Errorf("Expected canonical query: %s. Got: %s\n", expected, actual)
+	}
+}
+
+func TestSignatureV2(t *testing.T) {
+	var verifyErr error
+
+	key := mustParseSecretKey(t, secretKey)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyErr = VerifyRequestSignature(r, key, 0)
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{
+		APIKey:           apiKey,
+		SecretKey:        secretKey,
+		SignatureVersion: SignatureV2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	// GET requests are signed too
+	_, err = c.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifyErr != nil {
+		t.Errorf("Expected valid signature for GET request. Got: %v\n", verifyErr)
+	}
+
+	// POST request with query params and body
+	req, err := c.NewRequest(http.MethodPost, "/payment", map[string]interface{}{"b": 2, "a": 1}, &createPaymentRequest{Currency: "DERO", Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.SendSignedRequest(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifyErr != nil {
+		t.Errorf("Expected valid signature for POST request. Got: %v\n", verifyErr)
+	}
+
+	// The body MAC checked by the DERO Merchant server is still sent in X-Signature
+	if err := VerifyRequestSignatureV1(req, key); err != nil {
+		t.Errorf("Expected valid SignatureV1 alongside SignatureV2. Got: %v\n", err)
+	}
+	if req.Header.Get("X-Signature") == req.Header.Get(SignatureV2Header) {
+		t.Error("Expected SignatureV2 MAC in its own header")
+	}
+
+	// Signed request replayed against another endpoint
+	replayed := httptest.NewRequest(http.MethodPost, "/api/v1/payments", nil)
+	replayed.Header = req.Header.Clone()
+	if err := VerifyRequestSignature(replayed, key, 0); err != ErrInvalidSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSignature, err)
+	}
+
+	// Signed request verified too late
+	expired := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	err = signRequestV2(expired, key, time.Now().Add(-time.Hour), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRequestSignature(expired, key, 0); err != ErrSignatureExpired {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrSignatureExpired, err)
+	}
+	if err := VerifyRequestSignature(expired, key, 2*time.Hour); err != nil {
+		t.Errorf("Expected valid signature with larger max skew. Got: %v\n", err)
+	}
+
+	// Unsigned and wrongly versioned requests
+	unsigned := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	if err := VerifyRequestSignature(unsigned, key, 0); err != ErrNoRequestSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrNoRequestSignature, err)
+	}
+
+	expired.Header.Set(SignatureVersionHeader, strconv.Itoa(SignatureV1))
+	if err := VerifyRequestSignature(expired, key, 0); err != ErrUnsupportedSignatureVersion {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrUnsupportedSignatureVersion, err)
+	}
+
+	// Unsupported signature version in ClientOptions
+	_, err = NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey, SignatureVersion: 3})
+	if err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestSignatureV1(t *testing.T) {
+	var verifyErr error
+
+	key := mustParseSecretKey(t, secretKey)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verifyErr = VerifyRequestSignatureV1(r, key)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	req, err := c.NewRequest(http.MethodPost, "/payment", nil, &createPaymentRequest{Currency: "DERO", Amount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.SendSignedRequest(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verifyErr != nil {
+		t.Errorf("Expected valid signature. Got: %v\n", verifyErr)
+	}
+
+	// Signature of another body
+	tampered := httptest.NewRequest(http.MethodPost, "/api/v1/payment", strings.NewReader(`{"currency":"DERO","amount":2}`))
+	tampered.Header = req.Header.Clone()
+	if err := VerifyRequestSignatureV1(tampered, key); err != ErrInvalidSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSignature, err)
+	}
+
+	unsigned := httptest.NewRequest(http.MethodPost, "/api/v1/payment", strings.NewReader(`{}`))
+	if err := VerifyRequestSignatureV1(unsigned, key); err != ErrNoRequestSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrNoRequestSignature, err)
+	}
+}
@@ -0,0 +1,123 @@
+package deromerchant
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// flightGroup merges concurrent calls with the same key into a single call, whose result is shared by every caller.
+// The shared call runs with a context detached from the callers' cancellation, and is canceled only when every caller gave up,
+// so one caller's cancellation does not fail the others.
+// The shared call only carries the context values of the caller that started it (e.g. its TraceContext,
+// or the values read by a CredentialsProvider): the values of the callers that joined it are ignored.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	val     interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do calls fn once for all concurrent callers with the same key and returns its result.
+// If ctx is done before fn returns, Do returns ctx.Err() without waiting for fn.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	c, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		c = &flightCall{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		g.calls[key] = c
+
+		go func() {
+			c.val, c.err = fn(callCtx)
+
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+
+			cancel()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+
+		return nil, ctx.Err()
+	}
+}
+
+// coalesce calls fn through the flightGroup of the Client, merging concurrent calls with the same key.
+func (c *Client) coalesce(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if c.flights == nil || responseFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return c.flights.Do(ctx, key, fn)
+}
+
+// detachedContext carries the values of its parent (e.g. the TraceContext) but not its deadline or cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func copyPayment(p *Payment) *Payment {
+	if p == nil {
+		return nil
+	}
+
+	cp := *p
+	if p.Extra != nil {
+		cp.Extra = make(map[string]json.RawMessage, len(p.Extra))
+		for k, v := range p.Extra {
+			cp.Extra[k] = v
+		}
+	}
+	return &cp
+}
+
+func copyPayments(ps []*Payment) []*Payment {
+	if ps == nil {
+		return nil
+	}
+
+	cp := make([]*Payment, len(ps))
+	for i, p := range ps {
+		cp[i] = copyPayment(p)
+	}
+	return cp
+}
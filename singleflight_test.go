@@ -0,0 +1,122 @@
+package deromerchant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetPaymentCoalescing(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Write([]byte(`{"paymentID":"f30d35de693c2f6cee02f3a099c9bf4cdb75d0b42c5527a0bae967a4521c56cb","status":"pending"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	const callers = 10
+	var (
+		wg       sync.WaitGroup
+		payments = make([]*Payment, callers)
+		errs     = make([]error, callers)
+	)
+
+	// A caller that gives up does not fail the others
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	canceledErr := make(chan error)
+	go func() {
+		_, err := c.GetPaymentWithContext(canceledCtx, "f30d35de693c2f6cee02f3a099c9bf4cdb75d0b42c5527a0bae967a4521c56cb")
+		canceledErr <- err
+	}()
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			payments[i], errs[i] = c.GetPayment("f30d35de693c2f6cee02f3a099c9bf4cdb75d0b42c5527a0bae967a4521c56cb")
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Let every caller join the in-flight request
+	cancel()
+	if err := <-canceledErr; err != context.Canceled {
+		t.Errorf("Expected error: %v. Got: %v\n", context.Canceled, err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected 1 request. Got: %d\n", n)
+	}
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Errorf("Error not expected. Got: %v\n", errs[i])
+			continue
+		}
+		if payments[i].Status != PaymentStatusPending {
+			t.Errorf("Expected status: %s. Got: %s\n", PaymentStatusPending, payments[i].Status)
+		}
+	}
+
+	// Callers get their own copy of the shared result
+	payments[0].Status = PaymentStatusPaid
+	if payments[1].Status != PaymentStatusPending {
+		t.Error("Expected callers not to share the same Payment")
+	}
+}
+
+func TestGetPaymentsCoalescing(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Write([]byte(`[{"paymentID":"a"},{"paymentID":"b"}]`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	// Same set of Payment IDs in any order, with duplicates, is merged. An overlapping set is not
+	lists := [][]string{{"a", "b"}, {"b", "a"}, {"a", "b", "a"}, {"a", "c"}}
+
+	var wg sync.WaitGroup
+	for _, ids := range lists {
+		wg.Add(1)
+		go func(ids []string) {
+			defer wg.Done()
+			_, err := c.GetPayments(ids)
+			if err != nil {
+				t.Error(err)
+			}
+		}(ids)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Let every caller join the in-flight requests
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected 2 requests. Got: %d\n", n)
+	}
+}
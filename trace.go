@@ -0,0 +1,122 @@
+package deromerchant
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Tracer is the interface used by Client to create a span around every request sent to the DERO Merchant API.
+// Set it in ClientOptions to plug the SDK into a tracing system. If not provided, NoopTracer is used.
+//
+// Users of OpenTelemetry can write a small adapter: Start calls the OpenTelemetry tracer's Start,
+// then stores the W3C headers of the new span in the returned context with ContextWithTraceContext
+// (e.g. by running the OpenTelemetry propagator on an http.Header and passing the result to ExtractTraceContext).
+// Client will then send those headers along with the request.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span represents a single operation traced by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// NoopTracer is a Tracer that does nothing. It is the default Tracer of a Client.
+type NoopTracer struct{}
+
+// Start returns ctx unchanged and a Span that does nothing.
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End(err error)                              {}
+
+// TraceContext holds the W3C Trace Context propagated in the traceparent and tracestate headers.
+// See https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	TraceParent string
+	TraceState  string
+}
+
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+var traceParentRegexp = regexp.MustCompile(`^[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// Valid returns whether the traceparent of tc is well-formed.
+func (tc TraceContext) Valid() bool {
+	if !traceParentRegexp.MatchString(tc.TraceParent) {
+		return false
+	}
+
+	parts := strings.Split(tc.TraceParent, "-")
+	return parts[0] != "ff" && parts[1] != strings.Repeat("0", 32) && parts[2] != strings.Repeat("0", 16)
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx that carries tc.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the TraceContext carried by ctx, if any.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ExtractTraceContext returns the TraceContext sent in the traceparent and tracestate headers of h.
+// The second return value is false if h carries no (or an invalid) traceparent header.
+// As required by the W3C Trace Context, a traceparent with upper case hex digits is invalid.
+func ExtractTraceContext(h http.Header) (TraceContext, bool) {
+	tc := TraceContext{
+		TraceParent: strings.TrimSpace(h.Get(traceParentHeader)),
+		TraceState:  strings.TrimSpace(h.Get(traceStateHeader)),
+	}
+	if !tc.Valid() {
+		return TraceContext{}, false
+	}
+
+	return tc, true
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers of h from the TraceContext carried by ctx.
+// Headers are left untouched if ctx carries no valid TraceContext.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok || !tc.Valid() {
+		return
+	}
+
+	h.Set(traceParentHeader, tc.TraceParent)
+	if tc.TraceState != "" {
+		h.Set(traceStateHeader, tc.TraceState)
+	} else {
+		h.Del(traceStateHeader)
+	}
+}
+
+func (c *Client) startSpan(req *http.Request) (*http.Request, Span) {
+	tracer := c.tracer
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+
+	ctx, span := tracer.Start(req.Context(), "DeroMerchant "+req.Method+" "+req.URL.Path)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+
+	req = req.WithContext(ctx)
+	InjectTraceContext(ctx, req.Header)
+
+	return req, span
+}
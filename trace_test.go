@@ -0,0 +1,155 @@
+package deromerchant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *testSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type testTracer struct {
+	mu          sync.Mutex
+	spans       []*testSpan
+	traceParent string
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &testSpan{name: name, attributes: make(map[string]interface{})}
+	t.spans = append(t.spans, s)
+
+	return ContextWithTraceContext(ctx, TraceContext{TraceParent: t.traceParent}), s
+}
+
+func TestTraceContext(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		expectValid bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", false}, // Upper case is forbidden by W3C Trace Context
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00F067AA0BA902B7-01", false},
+		{"0A-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"not a traceparent", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		h := http.Header{}
+		h.Set("traceparent", test.traceParent)
+		h.Set("tracestate", "congo=t61rcWkgMzE")
+
+		tc, ok := ExtractTraceContext(h)
+		if ok != test.expectValid {
+			t.Errorf("Expected valid: %t for traceparent %q. Got: %t\n", test.expectValid, test.traceParent, ok)
+			continue
+		}
+
+		if ok {
+			out := http.Header{}
+			InjectTraceContext(ContextWithTraceContext(context.Background(), tc), out)
+
+			if out.Get("traceparent") != tc.TraceParent || out.Get("tracestate") != "congo=t61rcWkgMzE" {
+				t.Errorf("Expected injected headers to match extracted trace context. Got: %v\n", out)
+			}
+		}
+	}
+}
+
+func TestSendRequestTracing(t *testing.T) {
+	const (
+		incomingTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		spanTraceParent     = "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01"
+	)
+
+	var receivedTraceParent string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedTraceParent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"ping":"pong"}`))
+	}))
+	defer ts.Close()
+
+	// Without a Tracer, the trace context of the caller is propagated as is
+	c, err := NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	ctx := ContextWithTraceContext(context.Background(), TraceContext{TraceParent: incomingTraceParent})
+	_, err = c.PingWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receivedTraceParent != incomingTraceParent {
+		t.Errorf("Expected traceparent: %s. Got: %s\n", incomingTraceParent, receivedTraceParent)
+	}
+
+	// With a Tracer, the trace context of the span is propagated
+	tracer := &testTracer{traceParent: spanTraceParent}
+	c, err = NewClient(&ClientOptions{APIKey: apiKey, SecretKey: secretKey, Tracer: tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = ts.URL // Override Client's base URL to point to fake server
+
+	_, err = c.PingWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receivedTraceParent != spanTraceParent {
+		t.Errorf("Expected traceparent: %s. Got: %s\n", spanTraceParent, receivedTraceParent)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected 1 span. Got: %d\n", len(tracer.spans))
+	}
+
+	s := tracer.spans[0]
+	if !s.ended || s.err != nil {
+		t.Errorf("Expected span to be ended without error. Got ended: %t, error: %v\n", s.ended, s.err)
+	}
+	if s.attributes["http.status_code"] != http.StatusOK {
+		t.Errorf("Expected span attribute http.status_code: %d. Got: %v\n", http.StatusOK, s.attributes["http.status_code"])
+	}
+}
+
+func TestWebhookRequestContext(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	if _, ok := TraceContextFromContext(WebhookRequestContext(req)); ok {
+		t.Error("Expected no trace context")
+	}
+
+	req.Header.Set("traceparent", traceParent)
+	tc, ok := TraceContextFromContext(WebhookRequestContext(req))
+	if !ok || tc.TraceParent != traceParent {
+		t.Errorf("Expected traceparent: %s. Got: %s\n", traceParent, tc.TraceParent)
+	}
+}
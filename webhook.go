@@ -2,6 +2,7 @@ package deromerchant
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -17,32 +18,26 @@ var (
 )
 
 // VerifyWebhookSignature returns whether the signature of a webhook request payload, sent in the X-Signature header, is valid or not.
-// Function can return defined errrors ErrNoWebhookSignature or ErrInvalidSignature.
+// Function can return defined errrors ErrNoWebhookSignature or ErrInvalidSignature, or an error matching ErrInvalidSecretKey
+// if webhookSecretKey is not hex encoded or is shorter than MinSecretKeyLength.
 // Requests not verified by this function should not be considered valid.
 func VerifyWebhookSignature(req *http.Request, webhookSecretKey string) (bool, error) {
-	h := req.Header.Get("X-Signature")
-	if h == "" {
-		return false, ErrNoWebhookSignature
-	}
-
-	signature, err := hex.DecodeString(h)
+	key, err := ParseSecretKey(webhookSecretKey)
 	if err != nil {
 		return false, err
 	}
 
-	key, err := hex.DecodeString(webhookSecretKey)
-	if err != nil {
-		return false, err
-	}
+	return VerifyWebhookSignatureWithKey(req, key)
+}
 
-	body, err := ioutil.ReadAll(req.Body)
+// VerifyWebhookSignatureWithKey is like VerifyWebhookSignature but takes the Webhook Secret Key as a SecretKey, parsed once with ParseSecretKey.
+func VerifyWebhookSignatureWithKey(req *http.Request, webhookSecretKey *SecretKey) (bool, error) {
+	signature, body, err := readWebhookSignature(req)
 	if err != nil {
 		return false, err
 	}
 
-	req.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // Make body readable again
-
-	valid, err := validMAC(body, signature, key)
+	valid, err := webhookSecretKey.validMAC(body, signature)
 	if err != nil {
 		return false, err
 	}
@@ -54,15 +49,45 @@ func VerifyWebhookSignature(req *http.Request, webhookSecretKey string) (bool, e
 	return true, nil
 }
 
+// readWebhookSignature returns the signature sent in the X-Signature header of a webhook request and its body.
+func readWebhookSignature(req *http.Request) ([]byte, []byte, error) {
+	h := req.Header.Get("X-Signature")
+	if h == "" {
+		return nil, nil, ErrNoWebhookSignature
+	}
+
+	signature, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // Make body readable again
+
+	return signature, body, nil
+}
+
 // PaymentUpdateEvent is a struct that holds the unmarshalled JSON data of a webhook request.
 type PaymentUpdateEvent struct {
 	PaymentID string `json:"paymentID,omitempty"`
 	Status    string `json:"status,omitempty"`
+
+	// Extra holds the fields sent by the server unknown to the SDK, if the request is parsed with DecodeLenient.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // ParseWebhookRequest parses the body of a webhook request and returns it as a PaymentUpdateEvent object.
 // It should be used after the request has been verified by VerifyWebhookSignature.
 func ParseWebhookRequest(req *http.Request) (*PaymentUpdateEvent, error) {
+	return ParseWebhookRequestWithOptions(req, nil)
+}
+
+// ParseWebhookRequestWithOptions is like ParseWebhookRequest but decodes the body according to o. o is optional.
+func ParseWebhookRequestWithOptions(req *http.Request, o *DecodeOptions) (*PaymentUpdateEvent, error) {
 	body, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		return nil, err
@@ -71,7 +96,7 @@ func ParseWebhookRequest(req *http.Request) (*PaymentUpdateEvent, error) {
 	req.Body = ioutil.NopCloser(bytes.NewBuffer(body)) // Make body readable again
 
 	var e *PaymentUpdateEvent
-	err = json.Unmarshal(body, &e)
+	err = decodeJSON(body, &e, o)
 	if err != nil {
 		return nil, err
 	}
@@ -82,15 +107,33 @@ func ParseWebhookRequest(req *http.Request) (*PaymentUpdateEvent, error) {
 // VerifyAndParseWebhookRequest both verifies and parses a webhook request.
 // It is an alternative to calling VerifyWebhookSignature and ParseWebhookRequest individually.
 func VerifyAndParseWebhookRequest(req *http.Request, webhookSecretKey string) (bool, *PaymentUpdateEvent, error) {
+	return VerifyAndParseWebhookRequestWithOptions(req, webhookSecretKey, nil)
+}
+
+// VerifyAndParseWebhookRequestWithOptions is like VerifyAndParseWebhookRequest but decodes the body according to o. o is optional.
+func VerifyAndParseWebhookRequestWithOptions(req *http.Request, webhookSecretKey string, o *DecodeOptions) (bool, *PaymentUpdateEvent, error) {
 	valid, err := VerifyWebhookSignature(req, webhookSecretKey)
 	if err != nil {
 		return valid, nil, err
 	}
 
-	e, err := ParseWebhookRequest(req)
+	e, err := ParseWebhookRequestWithOptions(req, o)
 	if err != nil {
 		return valid, nil, err
 	}
 
 	return valid, e, nil
 }
+
+// WebhookRequestContext returns the context of a webhook request, carrying the TraceContext sent in its traceparent and tracestate headers (if any).
+// Passing it to the methods of Client (e.g. GetPaymentWithContext) continues the trace started by the sender of the webhook.
+func WebhookRequestContext(req *http.Request) context.Context {
+	ctx := req.Context()
+
+	tc, ok := ExtractTraceContext(req.Header)
+	if !ok {
+		return ctx
+	}
+
+	return ContextWithTraceContext(ctx, tc)
+}
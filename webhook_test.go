@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -92,7 +93,7 @@ func TestVerifyAndParseWebhookRequest(t *testing.T) {
 				t.FailNow()
 			}
 
-			if *actualEvent != *test.e {
+			if !reflect.DeepEqual(actualEvent, test.e) {
 				t.Errorf("\nExpected event:\n%+v\nGot:\n%+v\n", *test.e, *actualEvent)
 			}
 		} else {
@@ -0,0 +1,222 @@
+package deromerchant
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WebhookKey is a Webhook Secret Key of a WebhookKeySet.
+// NotBefore and NotAfter optionally limit the time window in which the key is accepted. Zero values mean no limit.
+type WebhookKey struct {
+	ID        string
+	Key       *SecretKey
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// activeAt returns whether the key is accepted at time t.
+func (k *WebhookKey) activeAt(t time.Time) bool {
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && t.After(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// PreviousWebhookKeySuffix is appended to the ID of a key replaced during a rotation by StoreRegistry.Load,
+// which keeps it in the set for WebhookKeyGracePeriod.
+const PreviousWebhookKeySuffix = ".previous"
+
+// WebhookKeyGracePeriod is how long StoreRegistry.Load keeps accepting the previous Webhook Secret Key of a store whose key changed,
+// so webhooks signed (or retried) with the previous key are not rejected during the rotation.
+const WebhookKeyGracePeriod = 24 * time.Hour
+
+// WebhookKeySet is an ordered set of Webhook Secret Keys used to verify webhook requests while rotating the Webhook Secret Key of a store.
+// A request is valid if its signature matches any of the keys active at the time of verification.
+// Keys are tried in order, so the key expected to match most requests should come first.
+// WebhookKeySet is safe for concurrent use.
+type WebhookKeySet struct {
+	clock Clock
+
+	mu       sync.RWMutex
+	keys     []WebhookKey
+	lastUsed map[string]time.Time
+}
+
+// NewWebhookKeySet returns a new WebhookKeySet holding keys.
+func NewWebhookKeySet(keys ...WebhookKey) (*WebhookKeySet, error) {
+	return NewWebhookKeySetWithClock(systemClock{}, keys...)
+}
+
+// NewWebhookKeySetWithClock is like NewWebhookKeySet but the time windows of the keys and LastUsed are measured with clk.
+func NewWebhookKeySetWithClock(clk Clock, keys ...WebhookKey) (*WebhookKeySet, error) {
+	if clk == nil {
+		clk = systemClock{}
+	}
+
+	s := &WebhookKeySet{
+		clock:    clk,
+		lastUsed: make(map[string]time.Time),
+	}
+
+	for _, k := range keys {
+		err := s.Add(k)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Add appends k to the set. It returns an error if k has no Key, its Key is shorter than MinSecretKeyLength or its ID is already in the set.
+func (s *WebhookKeySet) Add(k WebhookKey) error {
+	if k.Key == nil {
+		return errors.New("DeroMerchant: webhook key has no secret key")
+	}
+	if k.Key.tooShort() {
+		return ErrInvalidSecretKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.keys {
+		if existing.ID == k.ID {
+			return errors.New("DeroMerchant: duplicate webhook key ID " + k.ID)
+		}
+	}
+
+	s.keys = append(s.keys, k)
+	return nil
+}
+
+// Remove removes the key with ID id from the set. It returns whether the key was found.
+func (s *WebhookKeySet) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, k := range s.keys {
+		if k.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			delete(s.lastUsed, id)
+			return true
+		}
+	}
+
+	return false
+}
+
+// rotate inserts k first in the set. The key with the ID of k, if any, is kept under the ID k.ID+PreviousWebhookKeySuffix
+// until grace has passed, replacing any key previously kept under that ID.
+func (s *WebhookKeySet) rotate(k WebhookKey, grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previousID := k.ID + PreviousWebhookKeySuffix
+	keys := []WebhookKey{k}
+	for _, existing := range s.keys {
+		switch existing.ID {
+		case previousID:
+			continue
+		case k.ID:
+			notAfter := s.clock.Now().Add(grace)
+			if existing.NotAfter.IsZero() || existing.NotAfter.After(notAfter) {
+				existing.NotAfter = notAfter
+			}
+			existing.ID = previousID
+		}
+		keys = append(keys, existing)
+	}
+	s.keys = keys
+
+	delete(s.lastUsed, previousID)
+	if t, ok := s.lastUsed[k.ID]; ok {
+		s.lastUsed[previousID] = t
+		delete(s.lastUsed, k.ID)
+	}
+}
+
+// Keys returns a copy of the keys of the set, in order.
+func (s *WebhookKeySet) Keys() []WebhookKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]WebhookKey(nil), s.keys...)
+}
+
+// LastUsed returns the last time a request was verified with the key with ID id.
+// The second return value is false if the key never matched a request.
+// It can be used to find out when an old key stopped being used during a rotation.
+func (s *WebhookKeySet) LastUsed(id string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.lastUsed[id]
+	return t, ok
+}
+
+// Verify verifies the signature of a webhook request, sent in the X-Signature header, against the active keys of the set.
+// It returns the key that matched.
+// Function can return defined errors ErrNoWebhookSignature or ErrInvalidSignature (if no active key matched).
+func (s *WebhookKeySet) Verify(req *http.Request) (*WebhookKey, error) {
+	signature, body, err := readWebhookSignature(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.verifyMAC(body, signature)
+}
+
+// verifyMAC returns the active key of the set that generated signature from body.
+func (s *WebhookKeySet) verifyMAC(body, signature []byte) (*WebhookKey, error) {
+	now := s.clock.Now()
+
+	for _, k := range s.Keys() {
+		if !k.activeAt(now) {
+			continue
+		}
+
+		valid, err := k.Key.validMAC(body, signature)
+		if err != nil {
+			if err == ErrSecretKeyZeroed || err == ErrInvalidSecretKey {
+				continue
+			}
+			return nil, err
+		}
+
+		if valid {
+			s.mu.Lock()
+			s.lastUsed[k.ID] = now
+			s.mu.Unlock()
+
+			return &k, nil
+		}
+	}
+
+	return nil, ErrInvalidSignature
+}
+
+// VerifyAndParse both verifies and parses a webhook request. It returns the key that matched and the parsed event.
+func (s *WebhookKeySet) VerifyAndParse(req *http.Request) (*WebhookKey, *PaymentUpdateEvent, error) {
+	return s.VerifyAndParseWithOptions(req, nil)
+}
+
+// VerifyAndParseWithOptions is like VerifyAndParse but decodes the body according to o. o is optional.
+func (s *WebhookKeySet) VerifyAndParseWithOptions(req *http.Request, o *DecodeOptions) (*WebhookKey, *PaymentUpdateEvent, error) {
+	k, err := s.Verify(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e, err := ParseWebhookRequestWithOptions(req, o)
+	if err != nil {
+		return k, nil, err
+	}
+
+	return k, e, nil
+}
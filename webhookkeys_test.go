@@ -0,0 +1,91 @@
+package deromerchant
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWebhookKeySet(t *testing.T) {
+	const (
+		oldWebhookSecretKey     = "010f2b45384c57bd388bccb520722abd8d5a61f66ca71fcd25bf7942d067ca73"
+		newWebhookSecretKey     = "1e9a0eefcff11530a1bc247672e9ebcb712fc6ab82e0b54b0e586c8adc33b0c0"
+		expiredWebhookSecretKey = "b3cef2080cf82a010acba9bd00c9bd5797ec07767fbd7c08702a921d67c8155a"
+		unknownWebhookSecretKey = "bfe737bcdc5d8886a03be6e6c34c545d85ab8fa39052b9e3be36d3626c180a6f"
+	)
+
+	set, err := NewWebhookKeySet(
+		WebhookKey{ID: "new", Key: mustParseSecretKey(t, newWebhookSecretKey), NotBefore: time.Now().Add(-time.Minute)},
+		WebhookKey{ID: "old", Key: mustParseSecretKey(t, oldWebhookSecretKey)},
+		WebhookKey{ID: "expired", Key: mustParseSecretKey(t, expiredWebhookSecretKey), NotAfter: time.Now().Add(-time.Minute)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &PaymentUpdateEvent{PaymentID: "6c8dd967897d8c46879d75236027f4791816146bed38a259a1dbdb8e047c10b4", Status: "paid"}
+
+	tests := []struct {
+		key         string
+		expectedID  string
+		expectError bool
+	}{
+		{newWebhookSecretKey, "new", false},
+		{oldWebhookSecretKey, "old", false},
+		{expiredWebhookSecretKey, "", true},
+		{unknownWebhookSecretKey, "", true},
+	}
+
+	for _, test := range tests {
+		req, err := createWebhookRequest("http://localhost/webhook", e, test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k, event, err := set.VerifyAndParse(req)
+		if err != nil {
+			if !test.expectError {
+				t.Errorf("Error not expected. Got: %v\n", err)
+			} else if err != ErrInvalidSignature {
+				t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSignature, err)
+			}
+			continue
+		}
+
+		if test.expectError {
+			t.Errorf("Expected error. Got key: %s\n", k.ID)
+			continue
+		}
+
+		if k.ID != test.expectedID {
+			t.Errorf("Expected key: %s. Got: %s\n", test.expectedID, k.ID)
+		}
+		if !reflect.DeepEqual(event, e) {
+			t.Errorf("\nExpected event:\n%+v\nGot:\n%+v\n", *e, *event)
+		}
+	}
+
+	if _, ok := set.LastUsed("old"); !ok {
+		t.Error("Expected old key to have been used")
+	}
+	if _, ok := set.LastUsed("expired"); ok {
+		t.Error("Expected expired key not to have been used")
+	}
+
+	// Once the old key is removed, requests signed with it are rejected
+	if !set.Remove("old") {
+		t.Error("Expected old key to be removed")
+	}
+
+	req, err := createWebhookRequest("http://localhost/webhook", e, oldWebhookSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Verify(req); err != ErrInvalidSignature {
+		t.Errorf("Expected error: %v. Got: %v\n", ErrInvalidSignature, err)
+	}
+
+	if err := set.Add(WebhookKey{ID: "new", Key: mustParseSecretKey(t, oldWebhookSecretKey)}); err == nil {
+		t.Error("Expected error adding duplicate key ID")
+	}
+}